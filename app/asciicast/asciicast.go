@@ -0,0 +1,144 @@
+package asciicast
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Version is the version of asciicast format supported by ah.
+const Version = 2
+
+// Types of the events.
+const (
+	EventOutput = "o"
+	EventInput  = "i"
+	EventResize = "r"
+)
+
+// Header is the first line of asciicast file. See
+// https://docs.asciinema.org/manual/asciicast/v2/ for the details.
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Duration  float64           `json:"duration,omitempty"`
+	Command   string            `json:"command,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Event is a line of asciicast file after the header. It is encoded as
+// an array of time in seconds, type and data.
+type Event struct {
+	Time time.Duration
+	Type string
+	Data string
+}
+
+// MarshalJSON encodes the event as an array.
+func (e *Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{e.Time.Seconds(), e.Type, e.Data})
+}
+
+// UnmarshalJSON decodes the event from an array.
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var seconds float64
+	fields := []interface{}{&seconds, &e.Type, &e.Data}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if len(fields) != 3 {
+		return errors.New("Event should have time, type and data")
+	}
+	e.Time = time.Duration(seconds * float64(time.Second))
+
+	return nil
+}
+
+// FormatSize formats the data of the resize event.
+func FormatSize(width int, height int) string {
+	return fmt.Sprintf("%dx%d", width, height)
+}
+
+// ParseSize parses the data of the resize event.
+func ParseSize(data string) (width int, height int, err error) {
+	if _, err = fmt.Sscanf(data, "%dx%d", &width, &height); err != nil {
+		err = fmt.Errorf("Incorrect size %s: %v", data, err)
+	}
+	return
+}
+
+// Writer writes asciicast file line by line.
+type Writer struct {
+	encoder *json.Encoder
+}
+
+// NewWriter writes the header and returns the writer of the events.
+func NewWriter(output io.Writer, header *Header) (*Writer, error) {
+	header.Version = Version
+	encoder := json.NewEncoder(output)
+	if err := encoder.Encode(header); err != nil {
+		return nil, err
+	}
+
+	return &Writer{encoder: encoder}, nil
+}
+
+// WriteEvent writes the event.
+func (w *Writer) WriteEvent(event *Event) error {
+	return w.encoder.Encode(event)
+}
+
+// Reader reads asciicast file line by line.
+type Reader struct {
+	Header *Header
+
+	scanner *bufio.Scanner
+}
+
+// NewReader reads the header and returns the reader of the events.
+func NewReader(input io.Reader) (*Reader, error) {
+	scanner := bufio.NewScanner(input)
+	// output of full-screen apps may come in long lines.
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("Asciicast file is empty")
+	}
+
+	header := new(Header)
+	if err := json.Unmarshal(scanner.Bytes(), header); err != nil {
+		return nil, fmt.Errorf("Cannot parse asciicast header: %v", err)
+	}
+	if header.Version != Version {
+		return nil, fmt.Errorf("Version %d of asciicast is not supported", header.Version)
+	}
+
+	return &Reader{Header: header, scanner: scanner}, nil
+}
+
+// ReadEvent returns the next event. Empty lines are skipped.
+func (r *Reader) ReadEvent() (*Event, error) {
+	for r.scanner.Scan() {
+		if len(r.scanner.Bytes()) == 0 {
+			continue
+		}
+		event := new(Event)
+		if err := json.Unmarshal(r.scanner.Bytes(), event); err != nil {
+			return nil, fmt.Errorf("Cannot parse asciicast event: %v", err)
+		}
+		return event, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, io.EOF
+}
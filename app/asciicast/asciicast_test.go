@@ -0,0 +1,117 @@
+package asciicast
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoundTrip(t *testing.T) {
+	header := &Header{Width: 120, Height: 40, Timestamp: 1430000000, Duration: 2.5,
+		Command: "make", Env: map[string]string{"SHELL": "/bin/zsh"}}
+	events := []Event{
+		{Time: 0, Type: EventOutput, Data: "hello\r\n"},
+		{Time: 500 * time.Millisecond, Type: EventResize, Data: FormatSize(80, 24)},
+		{Time: 1500 * time.Millisecond, Type: EventOutput, Data: "\x1b[1m\"quoted\"\x1b[0m привет"},
+	}
+
+	buffer := new(bytes.Buffer)
+	writer, err := NewWriter(buffer, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for idx := range events {
+		if err = writer.WriteEvent(&events[idx]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 1+len(events) {
+		t.Fatalf("Recording has %d lines, expected %d", len(lines), 1+len(events))
+	}
+	if lines[1] != `[0,"o","hello\r\n"]` || lines[2] != `[0.5,"r","80x24"]` {
+		t.Errorf("Events are encoded as %q", lines[1:])
+	}
+
+	reader, err := NewReader(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := *header
+	expected.Version = Version
+	if !reflect.DeepEqual(reader.Header, &expected) {
+		t.Errorf("Header is %+v, expected %+v", reader.Header, expected)
+	}
+	for idx := range events {
+		event, err := reader.ReadEvent()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *event != events[idx] {
+			t.Errorf("Event %d is %+v, expected %+v", idx, event, events[idx])
+		}
+	}
+	if _, err = reader.ReadEvent(); err != io.EOF {
+		t.Errorf("Expected EOF after the last event, got %v", err)
+	}
+}
+
+func TestReader(t *testing.T) {
+	cases := []struct {
+		name      string
+		recording string
+		events    []Event
+		fails     bool
+	}{
+		{
+			name:      "empty lines",
+			recording: "{\"version\": 2}\n\n[1.25, \"o\", \"a\"]\n\n[2, \"i\", \"b\"]\n",
+			events: []Event{
+				{Time: 1250 * time.Millisecond, Type: EventOutput, Data: "a"},
+				{Time: 2 * time.Second, Type: EventInput, Data: "b"},
+			},
+		},
+		{name: "empty", recording: "", fails: true},
+		{name: "version 1", recording: "{\"version\": 1, \"stdout\": []}\n", fails: true},
+		{name: "broken header", recording: "{\"version\"\n", fails: true},
+		{name: "short event", recording: "{\"version\": 2}\n[1, \"o\"]\n", fails: true},
+		{name: "broken event", recording: "{\"version\": 2}\n{\"time\": 1}\n", fails: true},
+	}
+
+	for _, testCase := range cases {
+		reader, err := NewReader(strings.NewReader(testCase.recording))
+		var events []Event
+		for err == nil {
+			var event *Event
+			if event, err = reader.ReadEvent(); err == nil {
+				events = append(events, *event)
+			}
+		}
+		if testCase.fails {
+			if err == io.EOF {
+				t.Errorf("%s: recording has to be rejected", testCase.name)
+			}
+			continue
+		}
+		if err != io.EOF {
+			t.Errorf("%s: unexpected error %v", testCase.name, err)
+		}
+		if !reflect.DeepEqual(events, testCase.events) {
+			t.Errorf("%s: events are %+v, expected %+v", testCase.name, events, testCase.events)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	width, height, err := ParseSize(FormatSize(132, 43))
+	if err != nil || width != 132 || height != 43 {
+		t.Errorf("Size is parsed as %dx%d, error %v", width, height, err)
+	}
+	if _, _, err = ParseSize("wide"); err == nil {
+		t.Errorf("Incorrect size has to be rejected")
+	}
+}
@@ -0,0 +1,176 @@
+package commands
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/9seconds/ah/app/asciicast"
+	"github.com/9seconds/ah/app/environments"
+	"github.com/9seconds/ah/app/traces"
+	"github.com/9seconds/ah/app/utils"
+)
+
+func init() {
+	utils.DisableLogging()
+}
+
+func makeEnvironment(t *testing.T, history string) *environments.Environment {
+	dir, err := ioutil.TempDir("", "ah")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := &environments.Environment{
+		Shell:           environments.ShellZsh,
+		HistFile:        filepath.Join(dir, "history"),
+		TracesDir:       filepath.Join(dir, "traces"),
+		TmpDir:          filepath.Join(dir, "tmp"),
+		RecordsFileName: filepath.Join(dir, "records.ndjson"),
+	}
+	if err = ioutil.WriteFile(env.HistFile, []byte(history), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, directory := range []string{env.TracesDir, env.TmpDir} {
+		if err = os.Mkdir(directory, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return env
+}
+
+// captureStdout returns everything callback writes into stdout.
+func captureStdout(t *testing.T, callback func()) []byte {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan []byte)
+	go func() {
+		data, _ := ioutil.ReadAll(reader)
+		output <- data
+	}()
+	callback()
+	writer.Close()
+
+	return <-output
+}
+
+func TestAsciicastRoundTrip(t *testing.T) {
+	env := makeEnvironment(t, ": 1430000000:5;vim\n: 1430000010:0;ls\n")
+	defer os.RemoveAll(filepath.Dir(env.HistFile))
+
+	recording := `{"version": 2, "width": 100, "height": 30, "timestamp": 1430000001, "duration": 4.5, "env": {"SHELL": "/bin/zsh"}}
+[0.5, "o", "hello\r\n"]
+[1, "i", "q"]
+[2, "r", "120x40"]
+[3.25, "o", "bye"]
+`
+	castFileName := filepath.Join(env.TmpDir, "vim.cast")
+	if err := ioutil.WriteFile(castFileName, []byte(recording), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ImportAsciicast(castFileName, "1", env)
+
+	files, err := ioutil.ReadDir(env.TracesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("Import has to create one trace, got %d files", len(files))
+	}
+	trace, err := traces.Open(filepath.Join(env.TracesDir, files[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer trace.Close()
+	started := time.Unix(1430000001, 0)
+	if trace.Header.Command != "vim" || trace.Header.Shell != "zsh" || !trace.Header.PseudoTTY ||
+		!trace.Header.Started.Equal(started) || trace.Header.GetDuration() != 4500*time.Millisecond {
+		t.Errorf("Header of the imported trace is %+v", trace.Header)
+	}
+
+	reader, err := asciicast.NewReader(bytes.NewReader(captureStdout(t, func() { ExportAsciicast("1", env) })))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reader.Header.Width != 100 || reader.Header.Height != 30 || reader.Header.Timestamp != 1430000001 ||
+		reader.Header.Duration != 4.5 || reader.Header.Command != "vim" {
+		t.Errorf("Header of the exported recording is %+v", reader.Header)
+	}
+
+	var events []asciicast.Event
+	for {
+		event, err := reader.ReadEvent()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, *event)
+	}
+	expected := []asciicast.Event{
+		{Time: 500 * time.Millisecond, Type: asciicast.EventOutput, Data: "hello\r\n"},
+		{Time: 2 * time.Second, Type: asciicast.EventResize, Data: "120x40"},
+		{Time: 3250 * time.Millisecond, Type: asciicast.EventOutput, Data: "bye"},
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("Exported events are %+v, expected %+v", events, expected)
+	}
+}
+
+func TestSpaceChunks(t *testing.T) {
+	chunks := []*traces.Chunk{
+		{Stream: traces.StreamOutput, Data: []byte("a\nb\n")},
+		{Stream: traces.StreamOutput, Data: []byte("c")},
+	}
+	header := &traces.Header{Started: time.Unix(0, 0), Finished: time.Unix(3, 0)}
+
+	lines := spaceChunks(chunks, header)
+	var actual []traces.Chunk
+	for _, line := range lines {
+		actual = append(actual, *line)
+	}
+	expected := []traces.Chunk{
+		{Stream: traces.StreamOutput, Data: []byte("a\n"), Offset: 0},
+		{Stream: traces.StreamOutput, Data: []byte("b\n"), Offset: time.Second},
+		{Stream: traces.StreamOutput, Data: []byte("c"), Offset: 2 * time.Second},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Lines are %v, expected %v", actual, expected)
+	}
+
+	if lines = spaceChunks(chunks, nil); lines[2].Offset != 2*defaultEventInterval {
+		t.Errorf("Lines without duration have to be spaced by default interval, got %v", lines[2].Offset)
+	}
+}
+
+func TestSplitIncompleteRune(t *testing.T) {
+	cases := []struct {
+		data       string
+		complete   string
+		incomplete string
+	}{
+		{"", "", ""},
+		{"ascii", "ascii", ""},
+		{"при", "при", ""},
+		{"пр\xd0", "пр", "\xd0"},
+		{"a\xf0\x9f\x98", "a", "\xf0\x9f\x98"},
+		{"a\xf0\x9f\x98\x80", "a\xf0\x9f\x98\x80", ""},
+	}
+
+	for _, testCase := range cases {
+		complete, incomplete := splitIncompleteRune([]byte(testCase.data))
+		if string(complete) != testCase.complete || string(incomplete) != testCase.incomplete {
+			t.Errorf("splitIncompleteRune(%q) is %q, %q", testCase.data, complete, incomplete)
+		}
+	}
+}
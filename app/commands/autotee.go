@@ -11,6 +11,7 @@ import (
 	logrus "github.com/Sirupsen/logrus"
 
 	"github.com/9seconds/ah/app/environments"
+	"github.com/9seconds/ah/app/output"
 	"github.com/9seconds/ah/app/utils"
 )
 
@@ -73,10 +74,19 @@ func AutoTeeList(env *environments.Environment) {
 	}
 	sort.Strings(keys)
 
+	writer := getOutput(env)
 	for idx := 0; idx < len(keys); idx++ {
-		os.Stdout.WriteString(autoCommands[keys[idx]].String())
-		os.Stdout.WriteString("\n")
+		autoCommand := autoCommands[keys[idx]]
+		writeRecord(writer, &output.Record{
+			Text: autoCommand.String(),
+			Fields: []output.Field{
+				{Name: "command", Value: autoCommand.Command},
+				{Name: "interactive", Value: autoCommand.Interactive},
+				{Name: "tty", Value: autoCommand.PseudoTTY},
+			},
+		})
 	}
+	closeOutput(writer)
 }
 
 // AutoTeeAdd adds a commands to the list of commands which should be executed
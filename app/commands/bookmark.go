@@ -8,22 +8,24 @@ import (
 	"github.com/9seconds/ah/app/utils"
 )
 
-// Bookmark implements "b" (bookmark) command.
-func Bookmark(commandNumber int, bookmarkAs string, env *environments.Environment) {
-	if commandNumber < 0 {
-		utils.Logger.Panic("Command number should be >= 0")
-	}
+var bookmarkNameRegexp = utils.CreateRegexp(`^[A-Za-z_]\w*$`)
+
+// IsValidBookmarkName checks if the name may be used as a bookmark name.
+func IsValidBookmarkName(name string) bool {
+	return bookmarkNameRegexp.Match(name)
+}
 
-	commandsKeeper, err := historyentries.GetCommands(historyentries.GetCommandsAll, nil, env)
+// Bookmark implements "b" (bookmark) command.
+func Bookmark(reference string, bookmarkAs string, env *environments.Environment) {
+	command, _, err := historyentries.GetCommandByReference(reference, env)
 	if err != nil {
 		utils.Logger.Panic(err)
 	}
-	commands := commandsKeeper.Result().([]historyentries.HistoryEntry)
-	if len(commands) <= commandNumber {
-		utils.Logger.Panic("Command number does not exist")
-	}
-	command := commands[commandNumber-1]
 
+	saveBookmark(bookmarkAs, command.GetCommand(), env)
+}
+
+func saveBookmark(bookmarkAs string, content string, env *environments.Environment) {
 	filename := env.GetBookmarkFileName(bookmarkAs)
 	file, err := os.Create(filename)
 	if err != nil {
@@ -31,5 +33,5 @@ func Bookmark(commandNumber int, bookmarkAs string, env *environments.Environmen
 	}
 	defer file.Close()
 
-	file.WriteString(command.GetCommand())
+	file.WriteString(content)
 }
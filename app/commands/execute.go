@@ -9,19 +9,15 @@ import (
 	"github.com/9seconds/ah/app/utils"
 )
 
-// ExecuteCommandNumber executes command by its number in history file.
-func ExecuteCommandNumber(number int, interactive bool, pseudoTTY bool, env *environments.Environment) {
-	if number < 0 {
-		utils.Logger.Panic("Cannot find such command")
-	}
-
-	commands, err := historyentries.GetCommands(historyentries.GetCommandsPrecise, nil, env, number)
+// ExecuteCommandReference executes command by its reference (history number
+// optionally prefixed by the history source label).
+func ExecuteCommandReference(reference string, interactive bool, pseudoTTY bool, env *environments.Environment) {
+	command, sourceEnv, err := historyentries.GetCommandByReference(reference, env)
 	if err != nil {
 		utils.Logger.Panic(err)
 	}
-	command := commands.Result().(historyentries.HistoryEntry)
 
-	execute(command.GetCommand(), env.Shell, interactive, pseudoTTY)
+	execute(command.GetCommand(), sourceEnv.Shell, interactive, pseudoTTY)
 }
 
 // ExecuteBookmark executes command by its bookmark name.
@@ -37,7 +33,7 @@ func ExecuteBookmark(name string, interactive bool, pseudoTTY bool, env *environ
 func execute(command string, shell string, interactive bool, pseudoTTY bool) {
 	err := utils.Exec(command,
 		string(shell), interactive, pseudoTTY,
-		os.Stdin, os.Stdout, os.Stderr)
+		os.Stdin, os.Stdout, os.Stderr, nil)
 	if err != nil {
 		os.Exit(utils.GetStatusCode(err))
 	}
@@ -0,0 +1,143 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"time"
+	"unicode/utf8"
+
+	"github.com/9seconds/ah/app/asciicast"
+	"github.com/9seconds/ah/app/environments"
+	"github.com/9seconds/ah/app/traces"
+	"github.com/9seconds/ah/app/utils"
+)
+
+// Size of the terminal in asciicast recordings of the outputs without
+// stored window size.
+const (
+	defaultCastWidth  = 80
+	defaultCastHeight = 24
+)
+
+// defaultEventInterval is the interval between events of the outputs
+// without timings if duration of the command is unknown.
+const defaultEventInterval = 100 * time.Millisecond
+
+// ExportAsciicast implements export --asciicast command. It writes the
+// trace as asciicast v2 recording to stdout. Outputs without timings are
+// split into lines which are spaced evenly over the duration of the
+// command.
+func ExportAsciicast(argument string, env *environments.Environment) {
+	command, trace := openTrace(argument, env)
+	defer trace.Close()
+
+	var chunks []*traces.Chunk
+	for {
+		chunk, err := trace.ReadChunk()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			utils.Logger.Panic(err)
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	header := &asciicast.Header{
+		Width:     defaultCastWidth,
+		Height:    defaultCastHeight,
+		Timestamp: command.GetTimestamp(),
+		Command:   command.GetCommand(),
+		Env:       map[string]string{"SHELL": env.Shell},
+	}
+	if term := os.Getenv("TERM"); term != "" {
+		header.Env["TERM"] = term
+	}
+	if trace.Header != nil {
+		header.Timestamp = trace.Header.Started.Unix()
+		header.Command = trace.Header.Command
+		header.Duration = trace.Header.GetDuration().Seconds()
+		header.Env["SHELL"] = trace.Header.Shell
+	}
+	if len(chunks) > 0 {
+		if width, height, ok := chunks[0].WindowSize(); ok {
+			header.Width, header.Height = int(width), int(height)
+			chunks = chunks[1:]
+		}
+	}
+	if !trace.HasTimings() {
+		chunks = spaceChunks(chunks, trace.Header)
+	}
+
+	buffered := bufio.NewWriter(os.Stdout)
+	defer buffered.Flush()
+	writer, err := asciicast.NewWriter(buffered, header)
+	if err != nil {
+		utils.Logger.Panic(err)
+	}
+
+	// terminal expects the carriage return which pseudo TTY adds on its own.
+	newLines := trace.Header == nil || !trace.Header.PseudoTTY
+	var incomplete []byte
+	for _, chunk := range chunks {
+		event := &asciicast.Event{Time: chunk.Offset, Type: asciicast.EventOutput}
+		if width, height, ok := chunk.WindowSize(); ok {
+			event.Type = asciicast.EventResize
+			event.Data = asciicast.FormatSize(int(width), int(height))
+		} else {
+			// chunks may split UTF-8 characters, JSON strings cannot have
+			// them broken.
+			var data []byte
+			data, incomplete = splitIncompleteRune(append(incomplete, chunk.Data...))
+			if newLines {
+				data = bytes.Replace(data, []byte("\n"), []byte("\r\n"), -1)
+			}
+			if len(data) == 0 {
+				continue
+			}
+			event.Data = string(data)
+		}
+
+		if err := writer.WriteEvent(event); err != nil {
+			utils.Logger.Panic(err)
+		}
+	}
+}
+
+// spaceChunks splits chunks into lines and spaces them evenly over the
+// duration of the command.
+func spaceChunks(chunks []*traces.Chunk, header *traces.Header) (lines []*traces.Chunk) {
+	for _, chunk := range chunks {
+		for _, line := range bytes.SplitAfter(chunk.Data, []byte("\n")) {
+			if len(line) > 0 {
+				lines = append(lines, &traces.Chunk{Stream: chunk.Stream, Data: line})
+			}
+		}
+	}
+
+	interval := defaultEventInterval
+	if header != nil && header.GetDuration() > 0 && len(lines) > 0 {
+		interval = header.GetDuration() / time.Duration(len(lines))
+	}
+	for idx, line := range lines {
+		line.Offset = time.Duration(idx) * interval
+	}
+
+	return
+}
+
+// splitIncompleteRune splits the data into the part with complete UTF-8
+// characters and the incomplete character at the end.
+func splitIncompleteRune(data []byte) ([]byte, []byte) {
+	for idx := len(data) - 1; idx >= 0 && idx >= len(data)-utf8.UTFMax; idx-- {
+		if utf8.RuneStart(data[idx]) {
+			if !utf8.FullRune(data[idx:]) {
+				return data[:idx], data[idx:]
+			}
+			break
+		}
+	}
+
+	return data, nil
+}
@@ -0,0 +1,130 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+
+	logrus "github.com/Sirupsen/logrus"
+
+	"github.com/9seconds/ah/app/environments"
+	"github.com/9seconds/ah/app/historyentries"
+	"github.com/9seconds/ah/app/output"
+	"github.com/9seconds/ah/app/traces"
+	"github.com/9seconds/ah/app/utils"
+)
+
+// grepMaxLineSize is the maximal length of the line in the trace. Traces
+// are outputs of the programs so lines may be really long.
+const grepMaxLineSize = 4 * 1024 * 1024
+
+type grepMatch struct {
+	number int
+	line   string
+}
+
+type grepResult struct {
+	entry   historyentries.HistoryEntry
+	matches []grepMatch
+}
+
+// Grep implements grep command. It searches the pattern in the stored
+// outputs of the commands and shows matched lines with their commands.
+func Grep(pattern utils.Matcher, conditions []historyentries.Condition, env *environments.Environment) {
+	results := grepTraces(pattern, getTracedCommands(conditions, env), env)
+
+	writer := getOutput(env)
+	for _, result := range results {
+		header := result.entry.ToString(env, false)
+		for idx, match := range result.matches {
+			text := fmt.Sprintf("    %d: %s", match.number, match.line)
+			if idx == 0 {
+				text = header + "\n" + text
+			}
+			fields := append(getEntryFields(result.entry),
+				output.Field{Name: "line_number", Value: match.number},
+				output.Field{Name: "line", Value: match.line})
+			writeRecord(writer, &output.Record{Text: text, Fields: fields})
+		}
+	}
+	closeOutput(writer)
+}
+
+// getTracedCommands returns commands which have outputs stored. If several
+// commands have the same trace, the latest one is returned.
+func getTracedCommands(conditions []historyentries.Condition, env *environments.Environment) []historyentries.HistoryEntry {
+	commands := getAllCommands(nil, conditions, env)
+
+	seen := make(map[string]bool)
+	var traced []historyentries.HistoryEntry
+	for idx := len(commands) - 1; idx >= 0; idx-- {
+		traceName := commands[idx].GetTraceName()
+		if commands[idx].HasHistory() && !seen[traceName] {
+			seen[traceName] = true
+			traced = append(traced, commands[idx])
+		}
+	}
+
+	for left, right := 0, len(traced)-1; left < right; left, right = left+1, right-1 {
+		traced[left], traced[right] = traced[right], traced[left]
+	}
+
+	return traced
+}
+
+// grepTraces searches the pattern in the traces of the commands in parallel.
+// Results are returned in the order of the commands.
+func grepTraces(pattern utils.Matcher, commands []historyentries.HistoryEntry,
+	env *environments.Environment) []grepResult {
+	results := make([]grepResult, len(commands))
+	jobs := make(chan int, len(commands))
+	for idx := range commands {
+		jobs <- idx
+	}
+	close(jobs)
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < runtime.NumCPU(); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				matches, err := grepTrace(pattern, env.GetTraceFileName(commands[idx].GetTraceName()))
+				if err != nil {
+					utils.Logger.WithFields(logrus.Fields{
+						"command": commands[idx].GetCommand(),
+						"error":   err,
+					}).Warn("Cannot search in the trace")
+				}
+				results[idx] = grepResult{entry: commands[idx], matches: matches}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+// grepTrace returns lines of the trace which match the pattern. Trace is
+// decompressed on the fly so it is never read into memory completely.
+func grepTrace(pattern utils.Matcher, filename string) (matches []grepMatch, err error) {
+	trace, err := traces.Open(filename)
+	if err != nil {
+		return
+	}
+	defer trace.Close()
+
+	scanner := bufio.NewScanner(trace)
+	scanner.Buffer(make([]byte, 64*1024), grepMaxLineSize)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if pattern.Match(line) {
+			matches = append(matches, grepMatch{number: number, line: line})
+		}
+	}
+	err = scanner.Err()
+
+	return
+}
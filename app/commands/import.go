@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	logrus "github.com/Sirupsen/logrus"
+
+	"github.com/9seconds/ah/app/asciicast"
+	"github.com/9seconds/ah/app/environments"
+	"github.com/9seconds/ah/app/historyentries"
+	"github.com/9seconds/ah/app/traces"
+	"github.com/9seconds/ah/app/utils"
+)
+
+// ImportAsciicast implements import --asciicast command. It converts the
+// asciicast v2 recording into the trace of the command so it may be shown
+// and replayed as any other output. Existing output of the command is never
+// overwritten.
+func ImportAsciicast(castFileName string, argument string, env *environments.Environment) {
+	command, _, err := historyentries.GetCommandByReference(argument, env)
+	if err != nil {
+		utils.Logger.Panic(err)
+	}
+	filename := env.GetTraceFileName(command.GetTraceName())
+	if _, err := os.Stat(filename); err == nil {
+		utils.Logger.Panicf("Output for %s already exists", argument)
+	}
+
+	file, err := os.Open(castFileName)
+	if err != nil {
+		utils.Logger.Panic(err)
+	}
+	defer file.Close()
+	reader, err := asciicast.NewReader(file)
+	if err != nil {
+		utils.Logger.Panic(err)
+	}
+	castHeader := reader.Header
+
+	started := time.Unix(command.GetTimestamp(), 0)
+	if castHeader.Timestamp > 0 {
+		started = time.Unix(castHeader.Timestamp, 0)
+	}
+	writer, err := traces.NewWriter(env.TmpDir, started)
+	if err != nil {
+		utils.Logger.Panic("Cannot create temporary file")
+	}
+	defer writer.Close()
+
+	var chunks []*traces.Chunk
+	if castHeader.Width > 0 && castHeader.Height > 0 {
+		chunks = append(chunks, traces.NewResizeChunk(uint16(castHeader.Width), uint16(castHeader.Height), 0))
+	}
+	var finished time.Duration
+	for {
+		event, err := reader.ReadEvent()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			utils.Logger.Panic(err)
+		}
+
+		switch event.Type {
+		case asciicast.EventOutput:
+			chunks = append(chunks, &traces.Chunk{Stream: traces.StreamOutput, Data: []byte(event.Data), Offset: event.Time})
+		case asciicast.EventResize:
+			width, height, err := asciicast.ParseSize(event.Data)
+			if err != nil {
+				utils.Logger.WithField("error", err).Warn("Skip incorrect resize event")
+				continue
+			}
+			chunks = append(chunks, traces.NewResizeChunk(uint16(width), uint16(height), event.Time))
+		default:
+			continue
+		}
+		finished = event.Time
+	}
+	if castHeader.Duration > 0 {
+		finished = time.Duration(castHeader.Duration * float64(time.Second))
+	}
+
+	for _, chunk := range chunks {
+		if err := writer.WriteChunk(chunk); err != nil {
+			utils.Logger.Panic(err)
+		}
+	}
+
+	header := &traces.Header{
+		Command:   castHeader.Command,
+		Started:   started,
+		Finished:  started.Add(finished),
+		PseudoTTY: true,
+	}
+	if header.Command == "" {
+		header.Command = command.GetCommand()
+	}
+	if shell := castHeader.Env["SHELL"]; shell != "" {
+		header.Shell = filepath.Base(shell)
+	}
+	utils.Logger.WithFields(logrus.Fields{
+		"command": command,
+		"header":  header,
+	}).Info("Import asciicast")
+
+	if err := writer.Save(filename, header); err != nil {
+		utils.Logger.Panic(err)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	logrus "github.com/Sirupsen/logrus"
 
 	"github.com/9seconds/ah/app/environments"
+	"github.com/9seconds/ah/app/output"
 	"github.com/9seconds/ah/app/utils"
 )
 
@@ -31,6 +32,8 @@ func ListBookmarks(env *environments.Environment) {
 		"template": template,
 	}).Info("Calculated template to print")
 
+	writer := getOutput(env)
+
 	for _, fileInfo := range bookmarksFileInfos {
 		fileName := fileInfo.Name()
 
@@ -43,6 +46,13 @@ func ListBookmarks(env *environments.Environment) {
 			continue
 		}
 
-		fmt.Printf(template, fileName, string(content))
+		writeRecord(writer, &output.Record{
+			Text: fmt.Sprintf(template, fileName, string(content)),
+			Fields: []output.Field{
+				{Name: "name", Value: fileName},
+				{Name: "content", Value: string(content)},
+			},
+		})
 	}
+	closeOutput(writer)
 }
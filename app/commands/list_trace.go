@@ -2,48 +2,216 @@ package commands
 
 import (
 	"bufio"
-	"compress/gzip"
+	"bytes"
+	"fmt"
+	"io"
 	"os"
-	"strconv"
+	"strings"
+	"time"
+
+	term "github.com/docker/docker/pkg/term"
 
 	"github.com/9seconds/ah/app/environments"
 	"github.com/9seconds/ah/app/historyentries"
+	"github.com/9seconds/ah/app/output"
+	"github.com/9seconds/ah/app/traces"
 	"github.com/9seconds/ah/app/utils"
 )
 
-// ListTrace implements l command (list trace).
-func ListTrace(argument string, env *environments.Environment) {
-	number, err := strconv.Atoi(argument)
-	if err != nil || number < 0 {
-		utils.Logger.Panicf("Cannot convert argument to a command number: %s", argument)
+// TimestampsMode defines how timestamps of the output lines are shown.
+type TimestampsMode uint8
+
+// Modes of the timestamps.
+const (
+	TimestampsNone TimestampsMode = iota
+	TimestampsAbsolute
+	TimestampsRelative
+)
+
+// Colors of the stderr in the interleaved output.
+const (
+	stderrColor = "\x1b[31m"
+	colorReset  = "\x1b[0m"
+)
+
+const absoluteTimestampLayout = "15:04:05.000"
+
+// traceFormatter renders chunks of the trace as text. Lines are prefixed
+// with timestamps and long pauses are marked if required.
+type traceFormatter struct {
+	text       io.Writer
+	timestamps TimestampsMode
+	gap        time.Duration
+	started    time.Time
+	colored    bool
+	lineStart  bool
+	lastLine   time.Duration
+}
+
+// ListTrace implements l command (list trace). If streams are set, only
+// they are shown. Otherwise streams are interleaved and stderr is colored
+// if output goes to the terminal. Lines are prefixed with timestamps
+// according to the mode and pauses longer than gap are marked if gap is
+// set. Text output is streamed, structured formats need the whole output
+// within the record.
+func ListTrace(argument string, streams []traces.Stream, timestamps TimestampsMode, gap time.Duration,
+	env *environments.Environment) {
+	command, trace := openTrace(argument, env)
+	defer trace.Close()
+	if len(streams) > 0 && !trace.IsSeparated() {
+		utils.Logger.Panicf("Output for %s has no separate stdout and stderr", argument)
 	}
+	if (timestamps != TimestampsNone || gap > 0) && !trace.HasTimings() {
+		utils.Logger.Panicf("Output for %s has no timings", argument)
+	}
+
+	if env.Format != output.FormatText {
+		content := new(bytes.Buffer)
+		readChunks(trace, streams, func(chunk *traces.Chunk) {
+			content.Write(chunk.Data)
+		})
+
+		fields := append(getEntryFields(command), getTraceFields(trace.Header)...)
+		fields = append(fields, output.Field{Name: "output", Value: content.String()})
+
+		writer := getOutput(env)
+		writeRecord(writer, &output.Record{Fields: fields})
+		closeOutput(writer)
+		return
+	}
+
+	buffered := bufio.NewWriter(os.Stdout)
+	formatter := &traceFormatter{
+		text:       buffered,
+		timestamps: timestamps,
+		gap:        gap,
+		colored:    len(streams) == 0 && term.IsTerminal(os.Stdout.Fd()),
+		lineStart:  true,
+	}
+	if trace.Header != nil {
+		formatter.started = trace.Header.Started
+	}
+	readChunks(trace, streams, formatter.write)
+	// output is finished with line break as all text records are.
+	if !formatter.lineStart {
+		buffered.WriteString("\n")
+	}
+	if err := buffered.Flush(); err != nil {
+		utils.Logger.Panic(err)
+	}
+}
 
-	commands, err := historyentries.GetCommands(historyentries.GetCommandsPrecise, nil, env, number)
+// readChunks passes the chunks of the selected streams to the callback
+// one by one.
+func readChunks(trace *traces.Trace, streams []traces.Stream, callback func(*traces.Chunk)) {
+	for {
+		chunk, err := trace.ReadChunk()
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			utils.Logger.Panic(err)
+		}
+		if chunk.Stream != traces.StreamResize && isStreamSelected(chunk.Stream, streams) {
+			callback(chunk)
+		}
+	}
+}
+
+// openTrace opens the trace of the command by its reference.
+func openTrace(argument string, env *environments.Environment) (historyentries.HistoryEntry, *traces.Trace) {
+	command, _, err := historyentries.GetCommandByReference(argument, env)
 	if err != nil {
 		utils.Logger.Panic(err)
 	}
-	command := commands.Result().(historyentries.HistoryEntry)
 	hashFilename := command.GetTraceName()
 	filename := env.GetTraceFileName(hashFilename)
 	if _, err := os.Stat(filename); os.IsNotExist(err) {
 		utils.Logger.Panicf("Output for %s is not exist", argument)
 	}
 
-	file := utils.Open(filename)
-	defer file.Close()
-	ungzippedFile, err := gzip.NewReader(file)
+	trace, err := traces.Open(filename)
 	if err != nil {
 		utils.Logger.Panic(err)
 	}
-	defer ungzippedFile.Close()
 
-	scanner := bufio.NewScanner(ungzippedFile)
-	for scanner.Scan() {
-		os.Stdout.WriteString(scanner.Text())
-		os.Stdout.WriteString("\n")
+	return command, trace
+}
+
+func (tf *traceFormatter) write(chunk *traces.Chunk) {
+	colored := tf.colored && chunk.Stream == traces.StreamStderr
+
+	data := chunk.Data
+	for len(data) > 0 {
+		if tf.lineStart {
+			tf.writePrefix(chunk.Offset)
+			tf.lineStart = false
+		}
+
+		line := data
+		if idx := bytes.IndexByte(data, '\n'); idx >= 0 {
+			line = data[:idx+1]
+			tf.lineStart = true
+		}
+		data = data[len(line):]
+
+		if colored {
+			// color is reset before the line break so it does not leak into
+			// the next line.
+			content := bytes.TrimSuffix(line, []byte("\n"))
+			io.WriteString(tf.text, stderrColor)
+			tf.text.Write(content)
+			io.WriteString(tf.text, colorReset)
+			tf.text.Write(line[len(content):])
+		} else {
+			tf.text.Write(line)
+		}
 	}
+}
 
-	if err := scanner.Err(); err != nil {
-		utils.Logger.Panic(err)
+// writePrefix writes the mark of the pause before the line and the
+// timestamp of the line.
+func (tf *traceFormatter) writePrefix(offset time.Duration) {
+	if tf.gap > 0 && offset-tf.lastLine > tf.gap {
+		fmt.Fprintf(tf.text, "----- %v without output -----\n", (offset - tf.lastLine).Round(time.Millisecond))
+	}
+	tf.lastLine = offset
+
+	switch tf.timestamps {
+	case TimestampsAbsolute:
+		fmt.Fprintf(tf.text, "[%s] ", tf.started.Add(offset).Format(absoluteTimestampLayout))
+	case TimestampsRelative:
+		fmt.Fprintf(tf.text, "[%s] ", formatOffset(offset))
+	}
+}
+
+// formatOffset formats the time since the start of the command like
+// 00:01:02.345.
+func formatOffset(offset time.Duration) string {
+	milliseconds := int64(offset / time.Millisecond)
+	return fmt.Sprintf("%02d:%02d:%02d.%03d",
+		milliseconds/(60*60*1000), milliseconds/(60*1000)%60, milliseconds/1000%60, milliseconds%1000)
+}
+
+// getTraceFields returns fields of the trace header for structured formats.
+// Legacy traces have no header so the fields are empty.
+func getTraceFields(header *traces.Header) []output.Field {
+	if header == nil {
+		header = new(traces.Header)
+	}
+	var started, finished interface{}
+	if !header.Started.IsZero() {
+		started, finished = header.Started.Unix(), header.Finished.Unix()
+	}
+
+	return []output.Field{
+		{Name: "trace_version", Value: header.Version},
+		{Name: "trace_exit_status", Value: header.ExitStatus},
+		{Name: "trace_signal", Value: header.Signal},
+		{Name: "trace_started", Value: started},
+		{Name: "trace_finished", Value: finished},
+		{Name: "trace_shell", Value: header.Shell},
+		{Name: "trace_host", Value: header.Host},
+		{Name: "trace_output_size", Value: header.Size},
+		{Name: "trace_streams", Value: strings.Join(header.Streams, ",")},
 	}
 }
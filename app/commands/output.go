@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"os"
+
+	"github.com/9seconds/ah/app/environments"
+	"github.com/9seconds/ah/app/historyentries"
+	"github.com/9seconds/ah/app/output"
+	"github.com/9seconds/ah/app/utils"
+)
+
+// getOutput returns a writer of the records in the format set in the
+// environment.
+func getOutput(env *environments.Environment) output.Writer {
+	writer, err := output.NewWriter(env.Format, os.Stdout)
+	if err != nil {
+		utils.Logger.Panic(err)
+	}
+	return writer
+}
+
+// writeRecord writes the record and panics on errors.
+func writeRecord(writer output.Writer, record *output.Record) {
+	if err := writer.Write(record); err != nil {
+		utils.Logger.Panic(err)
+	}
+}
+
+// closeOutput finishes the output and panics on errors.
+func closeOutput(writer output.Writer) {
+	if err := writer.Close(); err != nil {
+		utils.Logger.Panic(err)
+	}
+}
+
+// getEntryFields returns fields of the history entry for structured formats.
+func getEntryFields(entry historyentries.HistoryEntry) []output.Field {
+	var duration interface{}
+	if entry.HasDuration() {
+		duration = int64(entry.GetDuration().Seconds())
+	}
+	var directory, exitStatus, host, tty interface{}
+	if record := entry.GetRecord(); record != nil {
+		directory, exitStatus, host, tty = record.Directory, record.ExitStatus, record.Host, record.TTY
+	}
+
+	return []output.Field{
+		{Name: "number", Value: entry.GetNumber()},
+		{Name: "source", Value: entry.GetSource()},
+		{Name: "timestamp", Value: entry.GetTimestamp()},
+		{Name: "duration", Value: duration},
+		{Name: "command", Value: entry.GetCommand()},
+		{Name: "trace", Value: entry.HasHistory()},
+		{Name: "trace_size", Value: entry.GetTraceSize()},
+		{Name: "cwd", Value: directory},
+		{Name: "exit_status", Value: exitStatus},
+		{Name: "host", Value: host},
+		{Name: "tty", Value: tty},
+	}
+}
@@ -0,0 +1,196 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	logrus "github.com/Sirupsen/logrus"
+
+	"github.com/9seconds/ah/app/environments"
+	"github.com/9seconds/ah/app/historyentries"
+	"github.com/9seconds/ah/app/picker"
+	"github.com/9seconds/ah/app/traces"
+	"github.com/9seconds/ah/app/utils"
+)
+
+// pickCandidate is a command which may be picked: either history entry or
+// bookmark.
+type pickCandidate struct {
+	entry    *historyentries.HistoryEntry
+	bookmark string
+	command  string
+}
+
+// Pick implements pick command. It shows full-screen picker of the
+// bookmarks and history commands and does the chosen action with the picked
+// one. Picker is drawn on the terminal so the printed command may be
+// captured by shell widget.
+func Pick(query string, env *environments.Environment) {
+	candidates := getPickCandidates(env)
+
+	items := make([]picker.Item, len(candidates))
+	for idx := range candidates {
+		items[idx] = getPickItem(&candidates[idx], env)
+	}
+
+	idx, action, err := picker.Pick(items, query)
+	if err != nil {
+		utils.Logger.Panic(err)
+	}
+	if action == picker.ActionCancel {
+		os.Exit(1)
+	}
+	candidate := candidates[idx]
+
+	utils.Logger.WithFields(logrus.Fields{
+		"command": candidate.command,
+		"action":  action,
+	}).Info("Command is picked")
+
+	switch action {
+	case picker.ActionPrint:
+		fmt.Println(candidate.command)
+	case picker.ActionTrace:
+		fmt.Printf("%s t -- %s\n", os.Args[0], quoteCommand(candidate.command))
+	case picker.ActionExecute:
+		executePicked(&candidate, env)
+	case picker.ActionBookmark:
+		bookmarkPicked(&candidate, env)
+	}
+}
+
+// getPickCandidates returns bookmarks and history commands, the most recent
+// commands go first. Only the latest occurrence of the command is kept.
+func getPickCandidates(env *environments.Environment) []pickCandidate {
+	var candidates []pickCandidate
+
+	bookmarks, err := env.GetBookmarksFileInfos()
+	if err != nil {
+		utils.Logger.WithField("error", err).Warn("Cannot list bookmarks")
+	}
+	for _, bookmark := range bookmarks {
+		content, err := ioutil.ReadFile(env.GetBookmarkFileName(bookmark.Name()))
+		if err != nil {
+			utils.Logger.WithFields(logrus.Fields{
+				"filename": bookmark.Name(),
+				"error":    err,
+			}).Warn("Cannot read a content of the file so skip")
+			continue
+		}
+		candidates = append(candidates, pickCandidate{bookmark: bookmark.Name(), command: string(content)})
+	}
+
+	commands := getAllCommands(nil, nil, env)
+	seen := make(map[string]bool)
+	for idx := len(commands) - 1; idx >= 0; idx-- {
+		if command := commands[idx].GetCommand(); !seen[command] {
+			seen[command] = true
+			candidates = append(candidates, pickCandidate{entry: &commands[idx], command: command})
+		}
+	}
+
+	return candidates
+}
+
+func getPickItem(candidate *pickCandidate, env *environments.Environment) picker.Item {
+	if candidate.entry == nil {
+		return picker.Item{
+			Text:   fmt.Sprintf("@%-12s %s", candidate.bookmark, candidate.command),
+			Search: candidate.bookmark + " " + candidate.command,
+			Preview: func(height int) []string {
+				return strings.Split(candidate.command, "\n")
+			},
+		}
+	}
+
+	entry := candidate.entry
+	return picker.Item{
+		Text:   entry.ToString(env, false),
+		Search: candidate.command,
+		Preview: func(height int) []string {
+			if !entry.HasHistory() {
+				return append([]string{"No output is stored for !" + entry.GetReference(), ""},
+					strings.Split(entry.GetCommand(), "\n")...)
+			}
+			lines, err := readTraceHead(env.GetTraceFileName(entry.GetTraceName()), height)
+			if err != nil {
+				return []string{fmt.Sprintf("Cannot read output: %v", err)}
+			}
+			return lines
+		},
+	}
+}
+
+// readTraceHead returns first lines of the trace. If line is rewritten with
+// carriage returns, only its last state is returned.
+func readTraceHead(filename string, count int) (lines []string, err error) {
+	trace, err := traces.Open(filename)
+	if err != nil {
+		return
+	}
+	defer trace.Close()
+
+	scanner := bufio.NewScanner(trace)
+	scanner.Buffer(make([]byte, 64*1024), grepMaxLineSize)
+	for len(lines) < count && scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		lines = append(lines, line[strings.LastIndex(line, "\r")+1:])
+	}
+	err = scanner.Err()
+
+	return
+}
+
+// executePicked executes the picked command in the shell. Command is
+// attached to the terminal because standard output may be captured by shell
+// widget.
+func executePicked(candidate *pickCandidate, env *environments.Environment) {
+	tty, err := os.OpenFile(picker.TerminalDevice, os.O_RDWR, 0)
+	if err != nil {
+		utils.Logger.Panic(err)
+	}
+	defer tty.Close()
+
+	shell := env.Shell
+	if candidate.entry != nil && candidate.entry.GetSource() != "" {
+		_, sourceEnv, err := historyentries.GetCommandByReference(candidate.entry.GetReference(), env)
+		if err != nil {
+			utils.Logger.Panic(err)
+		}
+		shell = sourceEnv.Shell
+	}
+
+	if err := utils.Exec(candidate.command, shell, true, false, tty, tty, tty, nil); err != nil {
+		os.Exit(utils.GetStatusCode(err))
+	}
+}
+
+// bookmarkPicked asks a name of the bookmark on the terminal and bookmarks
+// the picked command.
+func bookmarkPicked(candidate *pickCandidate, env *environments.Environment) {
+	tty, err := os.OpenFile(picker.TerminalDevice, os.O_RDWR, 0)
+	if err != nil {
+		utils.Logger.Panic(err)
+	}
+	defer tty.Close()
+
+	fmt.Fprintf(tty, "Bookmark %s as: ", candidate.command)
+	name, err := bufio.NewReader(tty).ReadString('\n')
+	if err != nil {
+		utils.Logger.Panic(err)
+	}
+	name = strings.TrimSpace(name)
+	if !IsValidBookmarkName(name) {
+		utils.Logger.Panic("Incorrect bookmark name!")
+	}
+
+	saveBookmark(name, candidate.command, env)
+}
+
+// quoteCommand quotes the command to be passed to shell as a single argument.
+func quoteCommand(command string) string {
+	return "'" + strings.Replace(command, "'", `'\''`, -1) + "'"
+}
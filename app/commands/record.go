@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"os"
+
+	logrus "github.com/Sirupsen/logrus"
+
+	"github.com/9seconds/ah/app/environments"
+	"github.com/9seconds/ah/app/historyentries"
+	"github.com/9seconds/ah/app/utils"
+)
+
+// Record implements record command. It is called by shell hooks after the
+// command is finished and stores its metadata for the latest history entry.
+// started is a timestamp when command was started, 0 if it is unknown.
+func Record(exitStatus int, started int64, directory string, tty string, env *environments.Environment) {
+	keeper, err := historyentries.GetCommands(historyentries.GetCommandsLast, nil, nil, env, 1)
+	if err != nil {
+		utils.Logger.Panic(err)
+	}
+	commands := keeper.Result().([]historyentries.HistoryEntry)
+	if len(commands) == 0 {
+		utils.Logger.Panic("History is empty")
+	}
+	entry := commands[len(commands)-1]
+
+	// shell may write history lazily so the latest entry could be the
+	// previous command. Its record is kept intact then.
+	if started > 0 && entry.GetTimestamp() > 0 && entry.GetTimestamp() < started-teeDelta {
+		utils.Logger.WithFields(logrus.Fields{
+			"entry":   entry,
+			"started": started,
+		}).Warn("The latest history entry is older than the command, skip")
+		return
+	}
+
+	if directory == "" {
+		if directory, err = os.Getwd(); err != nil {
+			utils.Logger.Panic(err)
+		}
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		utils.Logger.WithField("error", err).Warn("Cannot get a hostname")
+	}
+
+	record := &historyentries.EntryRecord{
+		Trace:      entry.GetTraceName(),
+		Directory:  directory,
+		ExitStatus: exitStatus,
+		Started:    started,
+		Finished:   environments.CreatedAt,
+		Host:       host,
+		TTY:        tty,
+	}
+	utils.Logger.WithFields(logrus.Fields{
+		"entry":  entry,
+		"record": record,
+	}).Info("Record the command")
+
+	if err := historyentries.AppendRecord(record, env); err != nil {
+		utils.Logger.Panic(err)
+	}
+}
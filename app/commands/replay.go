@@ -0,0 +1,218 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	logrus "github.com/Sirupsen/logrus"
+	term "github.com/docker/docker/pkg/term"
+
+	"github.com/9seconds/ah/app/environments"
+	"github.com/9seconds/ah/app/picker"
+	"github.com/9seconds/ah/app/traces"
+	"github.com/9seconds/ah/app/utils"
+)
+
+// replaySeekStep is how far arrow keys seek the replay.
+const replaySeekStep = 5 * time.Second
+
+// Escape sequences used by the replay.
+const (
+	// resizeWindowFormat asks terminal to resize the window to the given
+	// height and width.
+	resizeWindowFormat = "\x1b[8;%d;%dt"
+	// resetTerminal clears the screen and resets terminal modes.
+	resetTerminal = "\x1bc"
+	// resetAttributes resets colors and shows the cursor.
+	resetAttributes = "\x1b[0m\x1b[?25h"
+	keyRight        = "\x1b[C"
+	keyLeft         = "\x1b[D"
+)
+
+type replayer struct {
+	chunks []*traces.Chunk
+	// offsets are times of the chunks with idle pauses shortened.
+	offsets  []time.Duration
+	next     int
+	clock    time.Duration
+	speed    float64
+	paused   bool
+	newLines bool
+	resized  bool
+	out      io.Writer
+}
+
+// Replay implements l --replay command. Output is written to the terminal
+// as is with its original timing sped up by speed. Pauses longer than
+// idleLimit are shortened to it if idleLimit is set. Window is resized to
+// the sizes stored with the output of the pseudo TTY.
+//
+// Space pauses and resumes the replay, left and right arrows seek it,
+// + and - change the speed and q quits.
+func Replay(argument string, speed float64, idleLimit time.Duration, env *environments.Environment) {
+	_, trace := openTrace(argument, env)
+	defer trace.Close()
+	if !trace.HasTimings() {
+		utils.Logger.Panicf("Output for %s has no timings", argument)
+	}
+	if !term.IsTerminal(os.Stdout.Fd()) {
+		utils.Logger.Panic("Replay requires a terminal")
+	}
+
+	rp := &replayer{speed: speed, out: os.Stdout}
+	var previous, capped time.Duration
+	for {
+		chunk, err := trace.ReadChunk()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			utils.Logger.Panic(err)
+		}
+
+		delta := chunk.Offset - previous
+		previous = chunk.Offset
+		if idleLimit > 0 && delta > idleLimit {
+			delta = idleLimit
+		}
+		capped += delta
+		rp.chunks = append(rp.chunks, chunk)
+		rp.offsets = append(rp.offsets, capped)
+	}
+
+	if winsize, err := term.GetWinsize(os.Stdout.Fd()); err == nil {
+		defer func() {
+			if rp.resized {
+				fmt.Fprintf(rp.out, resizeWindowFormat, winsize.Height, winsize.Width)
+			}
+		}()
+	}
+	defer io.WriteString(rp.out, resetAttributes)
+
+	var keys <-chan []byte
+	if tty, err := os.OpenFile(picker.TerminalDevice, os.O_RDWR, 0); err == nil {
+		defer tty.Close()
+		if state, err := term.SetRawTerminal(tty.Fd()); err == nil {
+			defer term.RestoreTerminal(tty.Fd(), state)
+			keys = readKeys(tty)
+			// raw terminal does not move the cursor to the start of the line on
+			// line break, pseudo TTY has converted them already.
+			rp.newLines = !trace.Header.PseudoTTY
+		}
+	}
+	if keys == nil {
+		utils.Logger.Warn("Cannot read keys from the terminal, replay cannot be controlled")
+	}
+
+	rp.run(keys)
+}
+
+func readKeys(tty *os.File) <-chan []byte {
+	keys := make(chan []byte)
+	go func() {
+		defer close(keys)
+		for {
+			buffer := make([]byte, 16)
+			read, err := tty.Read(buffer)
+			if err != nil {
+				return
+			}
+			keys <- buffer[:read]
+		}
+	}()
+
+	return keys
+}
+
+func (rp *replayer) run(keys <-chan []byte) {
+	for rp.next < len(rp.chunks) {
+		var timeout <-chan time.Time
+		var timer *time.Timer
+		started := time.Now()
+		if !rp.paused {
+			timer = time.NewTimer(time.Duration(float64(rp.offsets[rp.next]-rp.clock) / rp.speed))
+			timeout = timer.C
+		}
+
+		select {
+		case <-timeout:
+			rp.clock = rp.offsets[rp.next]
+			rp.write(rp.chunks[rp.next])
+			rp.next++
+		case key, ok := <-keys:
+			if timer != nil {
+				timer.Stop()
+				rp.clock += time.Duration(float64(time.Since(started)) * rp.speed)
+				if rp.clock > rp.offsets[rp.next] {
+					rp.clock = rp.offsets[rp.next]
+				}
+			}
+			if !ok {
+				keys = nil
+				rp.paused = false
+			} else if !rp.control(string(key)) {
+				return
+			}
+		}
+	}
+}
+
+// control handles the key. It returns false if replay has to be stopped.
+func (rp *replayer) control(key string) bool {
+	switch key {
+	case "q", "\x03":
+		return false
+	case " ":
+		rp.paused = !rp.paused
+	case keyRight:
+		rp.seek(rp.clock + replaySeekStep)
+	case keyLeft:
+		rp.seek(rp.clock - replaySeekStep)
+	case "+":
+		rp.speed *= 2
+	case "-":
+		rp.speed /= 2
+	}
+	utils.Logger.WithFields(logrus.Fields{
+		"key":    key,
+		"clock":  rp.clock,
+		"speed":  rp.speed,
+		"paused": rp.paused,
+	}).Debug("Replay is controlled")
+
+	return true
+}
+
+// seek writes the output till target immediately. Terminal cannot be
+// rewound so the output is written from the start to seek backwards.
+func (rp *replayer) seek(target time.Duration) {
+	if target < 0 {
+		target = 0
+	}
+	if target < rp.clock {
+		io.WriteString(rp.out, resetTerminal)
+		rp.next = 0
+	}
+
+	for rp.next < len(rp.chunks) && rp.offsets[rp.next] <= target {
+		rp.write(rp.chunks[rp.next])
+		rp.next++
+	}
+	rp.clock = target
+}
+
+func (rp *replayer) write(chunk *traces.Chunk) {
+	if width, height, ok := chunk.WindowSize(); ok {
+		fmt.Fprintf(rp.out, resizeWindowFormat, height, width)
+		rp.resized = true
+		return
+	}
+
+	data := chunk.Data
+	if rp.newLines {
+		data = bytes.Replace(data, []byte("\n"), []byte("\r\n"), -1)
+	}
+	rp.out.Write(data)
+}
@@ -3,39 +3,251 @@ package commands
 import (
 	"os"
 
+	term "github.com/docker/docker/pkg/term"
+
 	"github.com/9seconds/ah/app/environments"
 	"github.com/9seconds/ah/app/historyentries"
+	"github.com/9seconds/ah/app/output"
 	"github.com/9seconds/ah/app/slices"
 	"github.com/9seconds/ah/app/utils"
 )
 
-// Show implements s (show) command.
-func Show(slice *slices.Slice, filter *utils.Regexp, env *environments.Environment) {
+// contextSeparator separates groups of the context entries.
+const contextSeparator = "--"
+
+// Show implements s (show) command. Entries are rendered with the given
+// template or with the default one if it is nil. If fuzzy patterns are set,
+// entries are ranked by the fuzzy score. If context is set, given number of
+// entries before and after matched ones are shown too.
+func Show(slice *slices.Slice, filter historyentries.Filter, conditions []historyentries.Condition, showDuration bool, unique bool,
+	fuzzyPatterns []string, contextBefore int, contextAfter int, entryTemplate *historyentries.EntryTemplate,
+	env *environments.Environment) {
+	if contextBefore > 0 || contextAfter > 0 {
+		showContext(slice, filter, conditions, showDuration, contextBefore, contextAfter, entryTemplate, env)
+		return
+	}
+	if len(fuzzyPatterns) > 0 {
+		showScored(slice, filter, conditions, showDuration, unique, fuzzyPatterns, entryTemplate, env)
+		return
+	}
+	if unique {
+		showUnique(slice, filter, conditions, showDuration, entryTemplate, env)
+		return
+	}
+
 	var commands []historyentries.HistoryEntry
 
-	if slice.Start >= 0 && slice.Finish >= 0 {
+	if len(env.Histories) > 0 {
+		merged, err := historyentries.GetMergedCommands(filter, conditions, env)
+		if err != nil {
+			utils.Logger.Panic(err)
+		}
+		commands = sliceCommands(merged, slice)
+	} else if slice.Start < 0 && slice.Finish < 0 {
+		keeper, err := historyentries.GetCommands(historyentries.GetCommandsLast,
+			filter, conditions, env, -slice.Start)
+		if err != nil {
+			return
+		}
+		commands = sliceCommands(keeper.Result().([]historyentries.HistoryEntry), slice)
+	} else if slice.Start >= 0 && slice.Finish >= 0 {
 		keeper, err := historyentries.GetCommands(historyentries.GetCommandsRange,
-			filter, env, slice.Start, slice.Finish)
+			filter, conditions, env, slice.Start, slice.Finish)
 		if err != nil {
 			return
 		}
 		commands = keeper.Result().([]historyentries.HistoryEntry)
 	} else {
-		keeper, err := historyentries.GetCommands(historyentries.GetCommandsAll, filter, env)
+		keeper, err := historyentries.GetCommands(historyentries.GetCommandsAll, filter, conditions, env)
 		if err != nil {
 			return
 		}
-		toBeRanged := keeper.Result().([]historyentries.HistoryEntry)
-		sliceStart := slices.GetSliceIndex(slice.Start, len(toBeRanged))
-		sliceFinish := slices.GetSliceIndex(slice.Finish, len(toBeRanged))
-		if sliceStart < 0 || sliceFinish < 0 || sliceFinish <= sliceStart {
-			return
-		}
-		commands = toBeRanged[sliceStart:sliceFinish]
+		commands = sliceCommands(keeper.Result().([]historyentries.HistoryEntry), slice)
 	}
 
+	writer := getOutput(env)
 	for idx := 0; idx < len(commands); idx++ {
-		os.Stdout.WriteString(commands[idx].ToString(env))
-		os.Stdout.WriteString("\n")
+		var text string
+		if entryTemplate != nil {
+			text = renderOrPanic(entryTemplate.Render(commands[idx], env, showDuration))
+		} else {
+			text = commands[idx].ToString(env, showDuration)
+		}
+		writeRecord(writer, &output.Record{
+			Text:   text,
+			Fields: getEntryFields(commands[idx]),
+		})
+	}
+	closeOutput(writer)
+}
+
+// showUnique shows identical commands collapsed. Slice is applied to the
+// collapsed commands so all history has to be read.
+func showUnique(slice *slices.Slice, filter historyentries.Filter, conditions []historyentries.Condition, showDuration bool,
+	entryTemplate *historyentries.EntryTemplate, env *environments.Environment) {
+	unique := historyentries.CollapseEntries(getAllCommands(filter, conditions, env))
+	sliceStart, sliceFinish, ok := getSliceBounds(slice, len(unique))
+	if !ok {
+		return
+	}
+
+	writer := getOutput(env)
+	for _, entry := range unique[sliceStart:sliceFinish] {
+		fields := append(getEntryFields(entry.HistoryEntry),
+			output.Field{Name: "count", Value: entry.GetCount()},
+			output.Field{Name: "first_timestamp", Value: entry.GetFirstTimestamp()})
+		var text string
+		if entryTemplate != nil {
+			text = renderOrPanic(entryTemplate.RenderUnique(entry, env, showDuration))
+		} else {
+			text = entry.ToString(env, showDuration)
+		}
+		writeRecord(writer, &output.Record{
+			Text:   text,
+			Fields: fields,
+		})
+	}
+	closeOutput(writer)
+}
+
+// getAllCommands returns all commands of the history or merged commands of
+// all history sources if they are configured.
+func getAllCommands(filter historyentries.Filter, conditions []historyentries.Condition,
+	env *environments.Environment) []historyentries.HistoryEntry {
+	if len(env.Histories) > 0 {
+		merged, err := historyentries.GetMergedCommands(filter, conditions, env)
+		if err != nil {
+			utils.Logger.Panic(err)
+		}
+		return merged
+	}
+
+	keeper, err := historyentries.GetCommands(historyentries.GetCommandsAll, filter, conditions, env)
+	if err != nil {
+		utils.Logger.Panic(err)
+	}
+	return keeper.Result().([]historyentries.HistoryEntry)
+}
+
+// showScored shows commands ranked by the fuzzy score. The best match goes
+// last so slice like "s 10" shows top 10 matches.
+func showScored(slice *slices.Slice, filter historyentries.Filter, conditions []historyentries.Condition, showDuration bool,
+	unique bool, fuzzyPatterns []string, entryTemplate *historyentries.EntryTemplate, env *environments.Environment) {
+	commands := getAllCommands(filter, conditions, env)
+	// the latest command is ah itself, slices skip it so it is skipped
+	// before ranking.
+	if len(commands) > 0 {
+		commands = commands[:len(commands)-1]
 	}
+	highlight := env.Format == output.FormatText && term.IsTerminal(os.Stdout.Fd())
+
+	var scored []historyentries.ScoredEntry
+	if unique {
+		scored = historyentries.ScoreUniqueEntries(historyentries.CollapseEntries(commands), fuzzyPatterns, highlight)
+	} else {
+		scored = historyentries.ScoreEntries(commands, fuzzyPatterns, highlight)
+	}
+	sliceStart, sliceFinish, ok := getSliceBounds(slice, len(scored)+1)
+	if !ok {
+		return
+	}
+	if sliceFinish > len(scored) {
+		sliceFinish = len(scored)
+	}
+
+	writer := getOutput(env)
+	for _, entry := range scored[sliceStart:sliceFinish] {
+		fields := getEntryFields(entry.HistoryEntry)
+		if unique {
+			fields = append(fields,
+				output.Field{Name: "count", Value: entry.GetCount()},
+				output.Field{Name: "first_timestamp", Value: entry.GetFirstTimestamp()})
+		}
+		fields = append(fields,
+			output.Field{Name: "score", Value: entry.GetScore()},
+			output.Field{Name: "positions", Value: entry.GetPositions()})
+		var text string
+		if entryTemplate != nil {
+			text = renderOrPanic(entryTemplate.RenderScored(entry, env, showDuration))
+		} else {
+			text = entry.ToString(env, showDuration)
+		}
+		writeRecord(writer, &output.Record{
+			Text:   text,
+			Fields: fields,
+		})
+	}
+	closeOutput(writer)
+}
+
+// showContext shows matched commands with their neighbours in the history.
+// Groups of the commands are separated with "--" lines in text format.
+// Slice is applied to the matched commands.
+func showContext(slice *slices.Slice, filter historyentries.Filter, conditions []historyentries.Condition, showDuration bool,
+	contextBefore int, contextAfter int, entryTemplate *historyentries.EntryTemplate, env *environments.Environment) {
+	commands := getAllCommands(nil, nil, env)
+	// the latest command is ah itself, it is neither matched nor shown
+	// as a context.
+	if len(commands) > 0 {
+		commands = commands[:len(commands)-1]
+	}
+
+	matches := historyentries.MatchEntries(commands, filter, conditions)
+	sliceStart, sliceFinish, ok := getSliceBounds(slice, len(matches)+1)
+	if !ok {
+		return
+	}
+	if sliceFinish > len(matches) {
+		sliceFinish = len(matches)
+	}
+	groups := historyentries.GetContextGroups(commands, matches[sliceStart:sliceFinish], contextBefore, contextAfter)
+
+	writer := getOutput(env)
+	for idx, group := range groups {
+		if idx > 0 && env.Format == output.FormatText {
+			writeRecord(writer, &output.Record{Text: contextSeparator})
+		}
+		for _, entry := range group {
+			var text string
+			if entryTemplate != nil {
+				text = renderOrPanic(entryTemplate.Render(entry.HistoryEntry, env, showDuration))
+			} else {
+				text = entry.ToString(env, showDuration)
+			}
+			writeRecord(writer, &output.Record{
+				Text:   text,
+				Fields: append(getEntryFields(entry.HistoryEntry), output.Field{Name: "match", Value: entry.IsMatched()}),
+			})
+		}
+	}
+	closeOutput(writer)
+}
+
+func renderOrPanic(text string, err error) string {
+	if err != nil {
+		utils.Logger.Panic(err)
+	}
+	return text
+}
+
+func sliceCommands(toBeRanged []historyentries.HistoryEntry, slice *slices.Slice) []historyentries.HistoryEntry {
+	sliceStart, sliceFinish, ok := getSliceBounds(slice, len(toBeRanged))
+	if !ok {
+		return nil
+	}
+	return toBeRanged[sliceStart:sliceFinish]
+}
+
+func getSliceBounds(slice *slices.Slice, length int) (sliceStart int, sliceFinish int, ok bool) {
+	sliceStart = slices.GetSliceIndex(slice.Start, length)
+	sliceFinish = slices.GetSliceIndex(slice.Finish, length)
+	if sliceStart < 0 || sliceFinish < 0 || sliceFinish <= sliceStart {
+		return
+	}
+	if sliceFinish > length {
+		sliceFinish = length
+	}
+	ok = true
+
+	return
 }
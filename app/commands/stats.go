@@ -0,0 +1,241 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/9seconds/ah/app/environments"
+	"github.com/9seconds/ah/app/historyentries"
+	"github.com/9seconds/ah/app/output"
+	"github.com/9seconds/ah/app/utils"
+)
+
+const statsBarWidth = 40
+
+type statsCounter struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type statsTrace struct {
+	Reference string `json:"reference"`
+	Command   string `json:"command"`
+	Size      int64  `json:"size"`
+}
+
+type historyStats struct {
+	Commands       int
+	UniqueCommands int
+	TracedCommands int
+	TracedShare    float64
+	Traces         int
+	TracesSize     int64
+	TopCommands    []statsCounter
+	TopExecutables []statsCounter
+	Hours          []statsCounter
+	Weekdays       []statsCounter
+	LargestTraces  []statsTrace
+}
+
+type countersByCount []statsCounter
+
+func (cbc countersByCount) Len() int {
+	return len(cbc)
+}
+
+func (cbc countersByCount) Less(i, j int) bool {
+	if cbc[i].Count == cbc[j].Count {
+		return cbc[i].Name < cbc[j].Name
+	}
+	return cbc[i].Count > cbc[j].Count
+}
+
+func (cbc countersByCount) Swap(i, j int) {
+	cbc[i], cbc[j] = cbc[j], cbc[i]
+}
+
+type tracesBySize []statsTrace
+
+func (tbs tracesBySize) Len() int {
+	return len(tbs)
+}
+
+func (tbs tracesBySize) Less(i, j int) bool {
+	return tbs[i].Size > tbs[j].Size
+}
+
+func (tbs tracesBySize) Swap(i, j int) {
+	tbs[i], tbs[j] = tbs[j], tbs[i]
+}
+
+// Stats implements stats command. It shows top lists of commands and
+// executables, activity by hours and weekdays and usage of the traces.
+func Stats(top int, conditions []historyentries.Condition, env *environments.Environment) {
+	stats := collectStats(getAllCommands(nil, conditions, env), top, env)
+
+	writer := getOutput(env)
+	writeRecord(writer, &output.Record{
+		Text: formatStats(stats),
+		Fields: []output.Field{
+			{Name: "commands", Value: stats.Commands},
+			{Name: "unique_commands", Value: stats.UniqueCommands},
+			{Name: "traced_commands", Value: stats.TracedCommands},
+			{Name: "traced_share", Value: stats.TracedShare},
+			{Name: "traces", Value: stats.Traces},
+			{Name: "traces_size", Value: stats.TracesSize},
+			{Name: "top_commands", Value: stats.TopCommands},
+			{Name: "top_executables", Value: stats.TopExecutables},
+			{Name: "hours", Value: stats.Hours},
+			{Name: "weekdays", Value: stats.Weekdays},
+			{Name: "largest_traces", Value: stats.LargestTraces},
+		},
+	})
+	closeOutput(writer)
+}
+
+func collectStats(commands []historyentries.HistoryEntry, top int, env *environments.Environment) *historyStats {
+	stats := &historyStats{
+		Commands: len(commands),
+		Hours:    make([]statsCounter, 24),
+		Weekdays: make([]statsCounter, 7),
+	}
+	for hour := range stats.Hours {
+		stats.Hours[hour].Name = fmt.Sprintf("%02d", hour)
+	}
+	for weekday := range stats.Weekdays {
+		stats.Weekdays[weekday].Name = time.Weekday(weekday).String()
+	}
+
+	commandCounts := make(map[string]int)
+	executableCounts := make(map[string]int)
+	traced := make(map[string]historyentries.HistoryEntry)
+
+	for _, entry := range commands {
+		commandCounts[entry.GetCommand()]++
+		if executable := getExecutable(entry.GetCommand()); executable != "" {
+			executableCounts[executable]++
+		}
+		if entry.HasHistory() {
+			stats.TracedCommands++
+			traced[entry.GetTraceName()] = entry
+		}
+		if entry.GetTimestamp() > 0 {
+			entryTime := entry.GetTime()
+			stats.Hours[entryTime.Hour()].Count++
+			stats.Weekdays[entryTime.Weekday()].Count++
+		}
+	}
+	stats.UniqueCommands = len(commandCounts)
+	if stats.Commands > 0 {
+		stats.TracedShare = float64(stats.TracedCommands) / float64(stats.Commands)
+	}
+	stats.TopCommands = getTopCounters(commandCounts, top)
+	stats.TopExecutables = getTopCounters(executableCounts, top)
+
+	files, err := env.GetTracesFileInfos()
+	if err != nil {
+		utils.Logger.WithField("error", err).Warn("Error on traces directory listing")
+	}
+	var traces []statsTrace
+	for _, file := range files {
+		stats.Traces++
+		stats.TracesSize += file.Size()
+
+		trace := statsTrace{Command: file.Name(), Size: file.Size()}
+		if entry, ok := traced[file.Name()]; ok {
+			trace.Reference = entry.GetReference()
+			trace.Command = entry.GetCommand()
+		}
+		traces = append(traces, trace)
+	}
+	sort.Stable(tracesBySize(traces))
+	if len(traces) > top {
+		traces = traces[:top]
+	}
+	stats.LargestTraces = traces
+
+	return stats
+}
+
+// getExecutable returns a name of the executable of the command line.
+// Environment variables set before the executable are skipped.
+func getExecutable(command string) string {
+	for _, chunk := range strings.Fields(command) {
+		if !strings.Contains(chunk, "=") {
+			return chunk
+		}
+	}
+	return ""
+}
+
+func getTopCounters(counts map[string]int, top int) []statsCounter {
+	counters := make([]statsCounter, 0, len(counts))
+	for name, count := range counts {
+		counters = append(counters, statsCounter{Name: name, Count: count})
+	}
+	sort.Sort(countersByCount(counters))
+
+	if len(counters) > top {
+		counters = counters[:top]
+	}
+	return counters
+}
+
+func formatStats(stats *historyStats) string {
+	buffer := new(bytes.Buffer)
+
+	fmt.Fprintf(buffer, "Commands:         %d\n", stats.Commands)
+	fmt.Fprintf(buffer, "Unique commands:  %d\n", stats.UniqueCommands)
+	fmt.Fprintf(buffer, "Traced commands:  %d (%.1f%%)\n", stats.TracedCommands, stats.TracedShare*100)
+	fmt.Fprintf(buffer, "Traces:           %d (%s)\n", stats.Traces, utils.FormatSize(stats.TracesSize))
+
+	formatCounters(buffer, "Top commands", stats.TopCommands, false)
+	formatCounters(buffer, "Top executables", stats.TopExecutables, false)
+	formatCounters(buffer, "Activity by hour", stats.Hours, true)
+	formatCounters(buffer, "Activity by weekday", stats.Weekdays, true)
+
+	if len(stats.LargestTraces) > 0 {
+		buffer.WriteString("\nLargest traces:\n")
+		for _, trace := range stats.LargestTraces {
+			reference := ""
+			if trace.Reference != "" {
+				reference = "!" + trace.Reference
+			}
+			fmt.Fprintf(buffer, "  %10s  %-7s  %s\n", utils.FormatSize(trace.Size), reference, trace.Command)
+		}
+	}
+
+	return buffer.String()
+}
+
+// formatCounters renders the counters as a table. If withBars is set,
+// counters are rendered as a histogram.
+func formatCounters(buffer *bytes.Buffer, title string, counters []statsCounter, withBars bool) {
+	if len(counters) == 0 {
+		return
+	}
+
+	maxCount := 1
+	nameLength := 1
+	for _, counter := range counters {
+		if counter.Count > maxCount {
+			maxCount = counter.Count
+		}
+		if len(counter.Name) > nameLength {
+			nameLength = len(counter.Name)
+		}
+	}
+
+	fmt.Fprintf(buffer, "\n%s:\n", title)
+	for _, counter := range counters {
+		if withBars {
+			bar := strings.Repeat("#", counter.Count*statsBarWidth/maxCount)
+			fmt.Fprintf(buffer, "  %-*s  %-*s  %d\n", nameLength, counter.Name, statsBarWidth, bar, counter.Count)
+		} else {
+			fmt.Fprintf(buffer, "  %7d  %s\n", counter.Count, counter.Name)
+		}
+	}
+}
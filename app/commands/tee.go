@@ -1,51 +1,84 @@
 package commands
 
 import (
-	"bufio"
-	"compress/gzip"
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/9seconds/ah/app/environments"
 	"github.com/9seconds/ah/app/historyentries"
+	"github.com/9seconds/ah/app/traces"
 	"github.com/9seconds/ah/app/utils"
 )
 
 const teeDelta = 1
 
-// Tee implements t (trace, tee) command.
-func Tee(input string, interactive bool, pseudoTTY bool, env *environments.Environment) {
-	output, err := ioutil.TempFile(env.TmpDir, "ah")
+// Tee implements t (trace, tee) command. Stdout and stderr are stored
+// separately if pseudo TTY is not used. If streams are set, only they are
+// stored.
+func Tee(input string, interactive bool, pseudoTTY bool, streams []traces.Stream, env *environments.Environment) {
+	if pseudoTTY && len(streams) > 0 {
+		utils.Logger.Panic("Streams cannot be separated with pseudo TTY")
+	}
+
+	started := time.Now()
+	trace, err := traces.NewWriter(env.TmpDir, started)
 	if err != nil {
 		utils.Logger.Panic("Cannot create temporary file")
 	}
 
-	bufferedOutput := bufio.NewWriter(output)
-	gzippedWrapper := utils.NewSynchronizedWriter(gzip.NewWriter(bufferedOutput))
-	combinedStdout := io.MultiWriter(os.Stdout, gzippedWrapper)
-	combinedStderr := io.MultiWriter(os.Stderr, gzippedWrapper)
+	var combinedStdout, combinedStderr io.Writer = os.Stdout, os.Stderr
+	var resized utils.Resized
+	if pseudoTTY {
+		// pseudo TTY merges the streams, everything goes to stdout.
+		combinedStdout = io.MultiWriter(os.Stdout, trace.Stream(traces.StreamOutput))
+		// window sizes are stored so full-screen apps may be replayed.
+		resized = func(width uint16, height uint16) {
+			if err := trace.Resize(width, height); err != nil {
+				utils.Logger.WithField("error", err).Warn("Cannot store a window size")
+			}
+		}
+	} else {
+		if isStreamSelected(traces.StreamStdout, streams) {
+			combinedStdout = io.MultiWriter(os.Stdout, trace.Stream(traces.StreamStdout))
+		}
+		if isStreamSelected(traces.StreamStderr, streams) {
+			combinedStderr = io.MultiWriter(os.Stderr, trace.Stream(traces.StreamStderr))
+		}
+	}
+
+	header := &traces.Header{
+		Command:     input,
+		Started:     started,
+		Shell:       env.Shell,
+		Interactive: interactive,
+		PseudoTTY:   pseudoTTY,
+	}
+	if header.Host, err = os.Hostname(); err != nil {
+		utils.Logger.WithField("error", err).Warn("Cannot get a hostname")
+	}
 
 	var commandError *exec.ExitError
 	defer func() {
 		// defer here because command may cause a panic but we do not want to lose any output
-		gzippedWrapper.Close()
-		bufferedOutput.Flush()
-		output.Close()
+		header.Finished = time.Now()
+		header.ExitStatus = utils.GetStatusCode(commandError)
+		header.Signal = utils.GetSignal(commandError)
 
 		if hash, err := getPreciseHash(input, env); err == nil {
-			err = os.Rename(output.Name(), env.GetTraceFileName(hash))
+			err = trace.Save(env.GetTraceFileName(hash), header)
 			if err != nil {
-				utils.Logger.Errorf("Cannot save trace: %v. Get it here: %s", err, output.Name())
+				utils.Logger.Errorf("Cannot save trace: %v. Get it here: %s", err, trace.Name())
 			} else {
-				os.Remove(output.Name())
+				trace.Close()
 			}
 		} else {
-			utils.Logger.Errorf("Error occured on fetching command number: %v", err)
+			trace.Finish()
+			utils.Logger.Errorf("Error occured on fetching command number: %v. Get output here: %s", err, trace.Name())
 		}
 
 		if commandError != nil {
@@ -55,11 +88,24 @@ func Tee(input string, interactive bool, pseudoTTY bool, env *environments.Envir
 
 	commandError = utils.Exec(input,
 		string(env.Shell), interactive, pseudoTTY,
-		os.Stdin, combinedStdout, combinedStderr)
+		os.Stdin, combinedStdout, combinedStderr, resized)
+}
+
+func isStreamSelected(stream traces.Stream, streams []traces.Stream) bool {
+	if len(streams) == 0 {
+		return true
+	}
+	for _, stored := range streams {
+		if stored == stream {
+			return true
+		}
+	}
+	return false
 }
 
 func getPreciseHash(cmd string, env *environments.Environment) (hash string, err error) {
-	commands, err := historyentries.GetCommands(historyentries.GetCommandsAll, nil, env)
+	commands, err := historyentries.GetCommands(historyentries.GetCommandsRecent, nil, nil, env,
+		int(environments.CreatedAt-teeDelta))
 	if err != nil {
 		err = fmt.Errorf("Cannot fetch commands list: %v", err)
 		return
@@ -6,12 +6,14 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"time"
 
 	strftime "github.com/jehiah/go-strftime"
 	homedir "github.com/mitchellh/go-homedir"
 	yaml "gopkg.in/yaml.v2"
 
+	"github.com/9seconds/ah/app/output"
 	"github.com/9seconds/ah/app/utils"
 )
 
@@ -19,16 +21,33 @@ const (
 	defaultAppDirName       = ".ah"
 	defaultTracesDirName    = "traces"
 	defaultBookmarksDirName = "bookmarks"
+	defaultIndexDirName     = "index"
 
 	defaultConfigFileName       = "config.yaml"
-	defaultZshHistFileName      = ".zsh_history"
-	defaultBashHistFileName     = ".bash_history"
 	defaultAutoCommandsFileName = "autocommands.gob"
+	defaultRecordsFileName      = "records.ndjson"
 
 	// ShellBash defines code name of the Bash shell
 	ShellBash = "bash"
 	// ShellZsh defines code name of the Z Shell
-	ShellZsh  = "zsh"
+	ShellZsh = "zsh"
+	// ShellFish defines code name of the Friendly Interactive Shell
+	ShellFish = "fish"
+	// ShellKsh defines code name of the Korn Shell
+	ShellKsh = "ksh"
+	// ShellMksh defines code name of the MirBSD Korn Shell
+	ShellMksh = "mksh"
+	// ShellTcsh defines code name of the TENEX C Shell
+	ShellTcsh = "tcsh"
+	// ShellPowerShell defines code name of the PowerShell
+	ShellPowerShell = "pwsh"
+
+	// BashRecordsLines means that each line of bash history is a separate
+	// command. This is how bash reads its history by default.
+	BashRecordsLines = "lines"
+	// BashRecordsTimestamps means that bash history commands are delimited
+	// by timestamp lines. Use it with "shopt -s cmdhist lithist".
+	BashRecordsTimestamps = "timestamps"
 )
 
 var (
@@ -40,20 +59,35 @@ var (
 	CreatedAt = time.Now().Unix()
 )
 
+// HistorySource defines a history file of some shell. Environment may
+// have several sources to show the merged history.
+type HistorySource struct {
+	Label    string `yaml:"label"`
+	Shell    string `yaml:"shell"`
+	HistFile string `yaml:"histfile"`
+}
+
 // Environment defines common structure which carries all information
 // about environment where ah is executed.
 type Environment struct {
-	Shell          string `yaml:"shell"`
-	HistFile       string `yaml:"histfile"`
-	HistTimeFormat string `yaml:"histtimeformat"`
+	Shell          string            `yaml:"shell"`
+	HistFile       string            `yaml:"histfile"`
+	HistTimeFormat string            `yaml:"histtimeformat"`
+	BashRecords    string            `yaml:"bashrecords"`
+	Histories      []HistorySource   `yaml:"histories"`
+	Format         string            `yaml:"format"`
+	Template       string            `yaml:"template"`
+	Templates      map[string]string `yaml:"templates"`
 
 	HomeDir      string `yaml:"homedir"`
 	AppDir       string `yaml:"appdir"`
 	TmpDir       string `yaml:"tmpdir"`
 	TracesDir    string `yaml:"tracesdir"`
 	BookmarksDir string `yaml:"bookmarksdir"`
+	IndexDir     string `yaml:"indexdir"`
 
 	AutoCommandsFileName string `yaml:"autocommands"`
+	RecordsFileName      string `yaml:"records"`
 	ConfigFileName       string `yaml:"config"`
 }
 
@@ -76,22 +110,55 @@ func (e *Environment) GetBookmarkFileName(name string) string {
 	return filepath.Join(e.BookmarksDir, name)
 }
 
-// GetHistFileName returns filename of the history file or error if something goes wrong (e.g unsupported shell).
-func (e *Environment) GetHistFileName() (fileName string, err error) {
-	fileName = e.HistFile
+// GetIndexFileName returns filename of the history index based on the given key.
+func (e *Environment) GetIndexFileName(key string) string {
+	return filepath.Join(e.IndexDir, key)
+}
+
+// GetHistorySources returns the list of history sources. If no sources are
+// configured, environment's own history file is the only source and it has
+// no label.
+func (e *Environment) GetHistorySources() []HistorySource {
+	if len(e.Histories) == 0 {
+		return []HistorySource{{Shell: e.Shell, HistFile: e.HistFile}}
+	}
 
-	if fileName == "" {
-		switch e.Shell {
-		case ShellBash:
-			fileName = filepath.Join(e.HomeDir, defaultBashHistFileName)
-		case ShellZsh:
-			fileName = filepath.Join(e.HomeDir, defaultZshHistFileName)
-		default:
-			err = fmt.Errorf("Shell %s is not supported", e.Shell)
+	sources := make([]HistorySource, len(e.Histories))
+	for idx, source := range e.Histories {
+		sources[idx] = source
+		if sources[idx].Shell == "" {
+			sources[idx].Shell = e.Shell
+		}
+		if expanded, err := homedir.Expand(source.HistFile); err == nil {
+			sources[idx].HistFile = expanded
+		}
+		if sources[idx].Label == "" {
+			sources[idx].Label = filepath.Base(sources[idx].HistFile)
 		}
 	}
 
-	return
+	return sources
+}
+
+// ForSource returns a copy of the environment which reads a history from
+// the given source.
+func (e *Environment) ForSource(source HistorySource) *Environment {
+	env := *e
+	env.Shell = source.Shell
+	env.HistFile = source.HistFile
+	env.Histories = nil
+
+	return &env
+}
+
+// GetTemplate returns a template of history entries by its name from
+// templates setting. If there is no such name, it is treated as template
+// itself.
+func (e *Environment) GetTemplate(nameOrTemplate string) string {
+	if template, ok := e.Templates[nameOrTemplate]; ok {
+		return template
+	}
+	return nameOrTemplate
 }
 
 // FormatTimeStamp is just a small wrapper around FormatTime method.
@@ -109,8 +176,21 @@ func (e *Environment) FormatTime(timestamp *time.Time) (formatted string) {
 }
 
 // GetTracesFileInfos returns file metadata structures on all traces.
+// Hidden files are traces which are being saved, they are skipped.
 func (e *Environment) GetTracesFileInfos() ([]os.FileInfo, error) {
-	return e.getFileNames(e.TracesDir)
+	files, err := e.getFileNames(e.TracesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	traces := files[:0]
+	for _, file := range files {
+		if !strings.HasPrefix(file.Name(), ".") {
+			traces = append(traces, file)
+		}
+	}
+
+	return traces, nil
 }
 
 // GetBookmarksFileInfos returns file metadata structures on all bookmarks.
@@ -148,17 +228,24 @@ func (e *Environment) ReadFromConfig() (configEnv *Environment, err error) {
 }
 
 func (e *Environment) String() string {
-	return fmt.Sprintf("<Environment(shell='%s', histFile='%s', histTimeFormat='%s', homeDir='%s', appDir='%s', tracesDir='%s', bookmarksDir='%s', tmpDir='%s', configFileName='%s', autoCommandsFileName='%s')>",
+	return fmt.Sprintf("<Environment(shell='%s', histFile='%s', histTimeFormat='%s', bashRecords='%s', histories=%v, format='%s', template='%s', templates=%v, homeDir='%s', appDir='%s', tracesDir='%s', bookmarksDir='%s', indexDir='%s', tmpDir='%s', configFileName='%s', autoCommandsFileName='%s', recordsFileName='%s')>",
 		e.Shell,
 		e.HistFile,
 		e.HistTimeFormat,
+		e.BashRecords,
+		e.Histories,
+		e.Format,
+		e.Template,
+		e.Templates,
 		e.HomeDir,
 		e.AppDir,
 		e.TracesDir,
 		e.BookmarksDir,
+		e.IndexDir,
 		e.TmpDir,
 		e.ConfigFileName,
-		e.AutoCommandsFileName)
+		e.AutoCommandsFileName,
+		e.RecordsFileName)
 }
 
 // MakeDefaultEnvironment creates environment with default settings.
@@ -168,15 +255,19 @@ func MakeDefaultEnvironment() (env *Environment) {
 	env.Shell = path.Base(os.Getenv("SHELL"))
 	env.HistFile = os.Getenv("HISTFILE")
 	env.HistTimeFormat = os.Getenv("HISTTIMEFORMAT")
+	env.BashRecords = BashRecordsLines
+	env.Format = output.FormatText
 
 	env.HomeDir = homeDir
 	env.AppDir = filepath.Join(homeDir, defaultAppDirName)
 	env.TracesDir = filepath.Join(env.AppDir, defaultTracesDirName)
 	env.BookmarksDir = filepath.Join(env.AppDir, defaultBookmarksDirName)
+	env.IndexDir = filepath.Join(env.AppDir, defaultIndexDirName)
 	env.TmpDir = defaultTmpDir
 
 	env.ConfigFileName = filepath.Join(env.AppDir, defaultConfigFileName)
 	env.AutoCommandsFileName = filepath.Join(env.AppDir, defaultAutoCommandsFileName)
+	env.RecordsFileName = filepath.Join(env.AppDir, defaultRecordsFileName)
 
 	return
 }
@@ -189,13 +280,24 @@ func MergeEnvironments(envs ...*Environment) (result *Environment) {
 		result.Shell = getNotEmpty(result.Shell, value.Shell)
 		result.HistFile = getNotEmpty(result.HistFile, value.HistFile)
 		result.HistTimeFormat = getNotEmpty(result.HistTimeFormat, value.HistTimeFormat)
+		result.BashRecords = getNotEmpty(result.BashRecords, value.BashRecords)
+		if len(value.Histories) > 0 {
+			result.Histories = value.Histories
+		}
+		result.Format = getNotEmpty(result.Format, value.Format)
+		result.Template = getNotEmpty(result.Template, value.Template)
+		if len(value.Templates) > 0 {
+			result.Templates = value.Templates
+		}
 		result.HomeDir = getNotEmpty(result.HomeDir, value.HomeDir)
 		result.AppDir = getNotEmpty(result.AppDir, value.AppDir)
 		result.TracesDir = getNotEmpty(result.TracesDir, value.TracesDir)
 		result.BookmarksDir = getNotEmpty(result.BookmarksDir, value.BookmarksDir)
+		result.IndexDir = getNotEmpty(result.IndexDir, value.IndexDir)
 		result.TmpDir = getNotEmpty(result.TmpDir, value.TmpDir)
 		result.ConfigFileName = getNotEmpty(result.ConfigFileName, value.ConfigFileName)
 		result.AutoCommandsFileName = getNotEmpty(result.AutoCommandsFileName, value.AutoCommandsFileName)
+		result.RecordsFileName = getNotEmpty(result.RecordsFileName, value.RecordsFileName)
 	}
 
 	return
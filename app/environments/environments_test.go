@@ -0,0 +1,38 @@
+package environments
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetTracesFileInfos(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ah")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"0123abcd", ".ah123456", "4567cdef"} {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = os.Mkdir(filepath.Join(dir, "directory"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	env := &Environment{TracesDir: dir}
+	files, err := env.GetTracesFileInfos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	if len(names) != 2 || names[0] != "0123abcd" || names[1] != "4567cdef" {
+		t.Errorf("Traces are %v, expected only complete traces", names)
+	}
+}
@@ -0,0 +1,208 @@
+package fuzzy
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Scores of the matching. Matched characters are rewarded, gaps between
+// them are penalized. Characters on the word boundaries and consecutive
+// characters get additional bonuses so "gst" matches "git status" better
+// than "gist".
+const (
+	scoreMatch        = 16
+	scoreGapStart     = -3
+	scoreGapExtension = -1
+
+	bonusBoundary    = 8
+	bonusCamelCase   = 7
+	bonusConsecutive = 5
+
+	// bonusFirstCharMultiplier emphasizes the boundary bonus of the first
+	// character of the pattern.
+	bonusFirstCharMultiplier = 2
+)
+
+const noScore = -1 << 30
+
+// Match is a result of the fuzzy matching.
+type Match struct {
+	// Score is the score of the match, higher is better.
+	Score int
+	// Positions are indexes of the matched runes of the text.
+	Positions []int
+}
+
+// Score matches the pattern with the text. Characters of the pattern have
+// to be found in the text in the same order. The best alignment is found.
+// Matching is case insensitive unless pattern has uppercase characters.
+// The second returned value is false if text does not match.
+func Score(pattern string, text string) (*Match, bool) {
+	patternRunes := []rune(pattern)
+	textRunes := []rune(text)
+	if len(patternRunes) == 0 {
+		return &Match{}, true
+	}
+
+	caseSensitive := strings.ToLower(pattern) != pattern
+	normalized := textRunes
+	if !caseSensitive {
+		normalized = []rune(strings.ToLower(text))
+		if len(normalized) != len(textRunes) {
+			normalized = make([]rune, len(textRunes))
+			for idx, character := range textRunes {
+				normalized[idx] = unicode.ToLower(character)
+			}
+		}
+	}
+
+	if !isSubsequence(patternRunes, normalized) {
+		return nil, false
+	}
+
+	bonuses := make([]int, len(textRunes))
+	for idx := range textRunes {
+		bonuses[idx] = getBonus(textRunes, idx)
+	}
+
+	// scores[i][j] is the best score of the pattern[:i+1] where pattern[i]
+	// is matched with text[j]. previous[i][j] is the position of pattern[i-1]
+	// in that alignment.
+	scores := make([][]int, len(patternRunes))
+	previous := make([][]int, len(patternRunes))
+	for row := range patternRunes {
+		scores[row] = make([]int, len(textRunes))
+		previous[row] = make([]int, len(textRunes))
+
+		bestGap, bestGapPosition := noScore, -1
+		for column := range textRunes {
+			scores[row][column] = noScore
+
+			// bestGap is the best score of the previous pattern character
+			// followed by at least one unmatched character.
+			if row > 0 && column >= 2 {
+				if bestGap != noScore {
+					bestGap += scoreGapExtension
+				}
+				if candidate := scores[row-1][column-2]; candidate != noScore && candidate+scoreGapStart >= bestGap {
+					bestGap, bestGapPosition = candidate+scoreGapStart, column-2
+				}
+			}
+
+			if normalized[column] != patternRunes[row] {
+				continue
+			}
+
+			if row == 0 {
+				scores[row][column] = scoreMatch + bonuses[column]*bonusFirstCharMultiplier
+				previous[row][column] = -1
+				continue
+			}
+
+			if bestGap != noScore {
+				scores[row][column] = bestGap + scoreMatch + bonuses[column]
+				previous[row][column] = bestGapPosition
+			}
+			if column > 0 && scores[row-1][column-1] != noScore {
+				consecutive := scores[row-1][column-1] + scoreMatch + bonuses[column] + bonusConsecutive
+				if consecutive >= scores[row][column] {
+					scores[row][column] = consecutive
+					previous[row][column] = column - 1
+				}
+			}
+		}
+	}
+
+	lastRow := len(patternRunes) - 1
+	bestColumn := -1
+	for column := range textRunes {
+		if scores[lastRow][column] != noScore && (bestColumn < 0 || scores[lastRow][column] > scores[lastRow][bestColumn]) {
+			bestColumn = column
+		}
+	}
+	if bestColumn < 0 {
+		return nil, false
+	}
+
+	match := &Match{
+		Score:     scores[lastRow][bestColumn],
+		Positions: make([]int, len(patternRunes)),
+	}
+	for row, column := lastRow, bestColumn; row >= 0; row-- {
+		match.Positions[row] = column
+		column = previous[row][column]
+	}
+
+	return match, true
+}
+
+// Highlight wraps runes of the text on the given positions with start and
+// end markers. Consecutive positions are wrapped together.
+func Highlight(text string, positions []int, start string, end string) string {
+	if len(positions) == 0 {
+		return text
+	}
+
+	marked := make(map[int]bool, len(positions))
+	for _, position := range positions {
+		marked[position] = true
+	}
+
+	var result []rune
+	highlighted := false
+	idx := 0
+	for _, character := range text {
+		if marked[idx] != highlighted {
+			if highlighted {
+				result = append(result, []rune(end)...)
+			} else {
+				result = append(result, []rune(start)...)
+			}
+			highlighted = !highlighted
+		}
+		result = append(result, character)
+		idx++
+	}
+	if highlighted {
+		result = append(result, []rune(end)...)
+	}
+
+	return string(result)
+}
+
+func isSubsequence(pattern []rune, text []rune) bool {
+	idx := 0
+	for _, character := range text {
+		if idx < len(pattern) && character == pattern[idx] {
+			idx++
+		}
+	}
+	return idx == len(pattern)
+}
+
+// getBonus returns the bonus of the character on the given position.
+// Characters which start words are rewarded.
+func getBonus(text []rune, idx int) int {
+	current := text[idx]
+	if !isWordCharacter(current) {
+		return 0
+	}
+	if idx == 0 {
+		return bonusBoundary
+	}
+
+	previous := text[idx-1]
+	switch {
+	case !isWordCharacter(previous):
+		return bonusBoundary
+	case unicode.IsLower(previous) && unicode.IsUpper(current):
+		return bonusCamelCase
+	case !unicode.IsDigit(previous) && unicode.IsDigit(current):
+		return bonusCamelCase
+	}
+	return 0
+}
+
+func isWordCharacter(character rune) bool {
+	return unicode.IsLetter(character) || unicode.IsDigit(character)
+}
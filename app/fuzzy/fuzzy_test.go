@@ -0,0 +1,88 @@
+package fuzzy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScore(t *testing.T) {
+	cases := []struct {
+		pattern   string
+		text      string
+		matched   bool
+		positions []int
+	}{
+		{"", "anything", true, nil},
+		{"make", "make test", true, []int{0, 1, 2, 3}},
+		{"kgp", "kubectl get pods", true, []int{0, 8, 12}},
+		{"gst", "git status", true, []int{0, 4, 5}},
+		{"fb", "FooBar", true, []int{0, 3}},
+		{"ab", "aab", true, []int{0, 2}},
+		{"MAKE", "make", false, nil},
+		{"Make", "Makefile", true, []int{0, 1, 2, 3}},
+		{"пр", "привет", true, []int{0, 1}},
+		{"tm", "make", false, nil},
+		{"long pattern", "long", false, nil},
+	}
+
+	for _, testCase := range cases {
+		match, matched := Score(testCase.pattern, testCase.text)
+		if matched != testCase.matched {
+			t.Errorf("Score(%q, %q) matched is %t, expected %t", testCase.pattern, testCase.text, matched, testCase.matched)
+			continue
+		}
+		if matched && !reflect.DeepEqual(match.Positions, testCase.positions) &&
+			!(len(match.Positions) == 0 && len(testCase.positions) == 0) {
+			t.Errorf("Score(%q, %q) positions are %v, expected %v",
+				testCase.pattern, testCase.text, match.Positions, testCase.positions)
+		}
+	}
+}
+
+func TestScoreRanking(t *testing.T) {
+	cases := []struct {
+		pattern string
+		better  string
+		worse   string
+	}{
+		{"gst", "git status", "gist"},
+		{"make", "make test", "cmake test"},
+		{"fb", "FooBar", "foobar"},
+		{"test", "go test ./...", "the best of times"},
+		{"kgp", "kubectl get pods", "xkxgxp"},
+	}
+
+	for _, testCase := range cases {
+		better, ok := Score(testCase.pattern, testCase.better)
+		if !ok {
+			t.Fatalf("%q does not match %q", testCase.pattern, testCase.better)
+		}
+		worse, ok := Score(testCase.pattern, testCase.worse)
+		if !ok {
+			t.Fatalf("%q does not match %q", testCase.pattern, testCase.worse)
+		}
+		if better.Score <= worse.Score {
+			t.Errorf("%q: %q has score %d, not better than %d of %q",
+				testCase.pattern, testCase.better, better.Score, worse.Score, testCase.worse)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	cases := []struct {
+		text      string
+		positions []int
+		expected  string
+	}{
+		{"make test", nil, "make test"},
+		{"make test", []int{0, 1, 2, 3}, "[make] test"},
+		{"git status", []int{0, 4, 5}, "[g]it [st]atus"},
+		{"привет", []int{1, 5}, "п[р]иве[т]"},
+	}
+
+	for _, testCase := range cases {
+		if actual := Highlight(testCase.text, testCase.positions, "[", "]"); actual != testCase.expected {
+			t.Errorf("Highlight(%q, %v) is %q, expected %q", testCase.text, testCase.positions, actual, testCase.expected)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package historyentries
+
+import "time"
+
+// Condition is a predicate on the parsed history entry. Entries which do
+// not satisfy the condition are not committed into the keeper.
+type Condition func(*HistoryEntry) bool
+
+// SlowerThan returns a condition which passes entries executed longer
+// than given duration. Durations recorded by shell hooks are taken from
+// records.
+func SlowerThan(duration time.Duration, records Records) Condition {
+	return func(entry *HistoryEntry) bool {
+		entry = withRecord(entry, records)
+		return entry.HasDuration() && entry.GetDuration() > duration
+	}
+}
+
+// FasterThan returns a condition which passes entries executed faster
+// than given duration. Durations recorded by shell hooks are taken from
+// records.
+func FasterThan(duration time.Duration, records Records) Condition {
+	return func(entry *HistoryEntry) bool {
+		entry = withRecord(entry, records)
+		return entry.HasDuration() && entry.GetDuration() < duration
+	}
+}
+
+// Since returns a condition which passes entries executed at the given
+// time or later. Entries without timestamps are filtered out.
+func Since(since time.Time) Condition {
+	timestamp := since.Unix()
+	return func(entry *HistoryEntry) bool {
+		return entry.timestamp > 0 && entry.timestamp >= timestamp
+	}
+}
+
+// Until returns a condition which passes entries executed before the given
+// time. Entries without timestamps are filtered out.
+func Until(until time.Time) Condition {
+	timestamp := until.Unix()
+	return func(entry *HistoryEntry) bool {
+		return entry.timestamp > 0 && entry.timestamp < timestamp
+	}
+}
+
+// Failed returns a condition which passes entries recorded with non-zero
+// exit status. Entries without records are filtered out.
+func Failed(records Records) Condition {
+	return func(entry *HistoryEntry) bool {
+		record, ok := records[entry.GetTraceName()]
+		return ok && record.ExitStatus != 0
+	}
+}
+
+// InDirectory returns a condition which passes entries recorded in the
+// given directory. Entries without records are filtered out.
+func InDirectory(directory string, records Records) Condition {
+	return func(entry *HistoryEntry) bool {
+		record, ok := records[entry.GetTraceName()]
+		return ok && record.Directory == directory
+	}
+}
+
+func matchConditions(entry *HistoryEntry, conditions []Condition) bool {
+	for _, condition := range conditions {
+		if !condition(entry) {
+			return false
+		}
+	}
+	return true
+}
+
+// withRecord returns the entry with its record attached. Conditions are
+// checked before records are attached to the entries so a copy of the
+// entry is made if the record is found.
+func withRecord(entry *HistoryEntry, records Records) *HistoryEntry {
+	if entry.record != nil || entry.hasElapsed || len(records) == 0 {
+		return entry
+	}
+	if record, ok := records[entry.GetTraceName()]; ok {
+		recorded := *entry
+		recorded.record = record
+		return &recorded
+	}
+	return entry
+}
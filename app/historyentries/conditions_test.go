@@ -0,0 +1,38 @@
+package historyentries
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDurationConditions(t *testing.T) {
+	recorded := HistoryEntry{number: 1, command: "make", timestamp: 1430000000}
+	records := Records{recorded.GetTraceName(): &EntryRecord{Started: 1430000000, Finished: 1430000060}}
+	cases := []struct {
+		name      string
+		entry     HistoryEntry
+		condition Condition
+		expected  bool
+	}{
+		{"elapsed slower", HistoryEntry{command: "ls", hasElapsed: true, elapsed: 60}, SlowerThan(30*time.Second, nil), true},
+		{"elapsed not slower", HistoryEntry{command: "ls", hasElapsed: true, elapsed: 10}, SlowerThan(30*time.Second, nil), false},
+		{"elapsed faster", HistoryEntry{command: "ls", hasElapsed: true, elapsed: 10}, FasterThan(30*time.Second, nil), true},
+		{"zero elapsed faster", HistoryEntry{command: "ls", hasElapsed: true}, FasterThan(30*time.Second, nil), true},
+		{"unknown slower", HistoryEntry{command: "ls"}, SlowerThan(0, records), false},
+		{"unknown faster", HistoryEntry{command: "ls"}, FasterThan(time.Hour, records), false},
+		{"recorded slower", recorded, SlowerThan(30*time.Second, records), true},
+		{"recorded faster", recorded, FasterThan(30*time.Second, records), false},
+		{"attached record", HistoryEntry{record: records[recorded.GetTraceName()]}, SlowerThan(30*time.Second, nil), true},
+		{"unfinished record", HistoryEntry{record: &EntryRecord{Finished: 1430000060}}, FasterThan(time.Hour, nil), false},
+	}
+
+	for _, testCase := range cases {
+		entry := testCase.entry
+		if actual := testCase.condition(&entry); actual != testCase.expected {
+			t.Errorf("%s: condition is %t, expected %t", testCase.name, actual, testCase.expected)
+		}
+		if entry.record != testCase.entry.record {
+			t.Errorf("%s: condition has changed the entry", testCase.name)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package historyentries
+
+// ContextEntry is an entry shown with the search results. It is either
+// a matched entry or its neighbour in the history.
+type ContextEntry struct {
+	HistoryEntry
+
+	matched bool
+}
+
+// IsMatched tells if entry is matched, not a context one.
+func (ce ContextEntry) IsMatched() bool {
+	return ce.matched
+}
+
+// MatchEntries returns indexes of the entries which pass the filter and the
+// conditions.
+func MatchEntries(entries []HistoryEntry, filter Filter, conditions []Condition) (matches []int) {
+	for idx := range entries {
+		if matchFilter(&entries[idx], filter) && matchConditions(&entries[idx], conditions) {
+			matches = append(matches, idx)
+		}
+	}
+
+	return
+}
+
+// GetContextGroups returns matched entries with given number of entries
+// before and after them. Matched entries which contexts overlap or adjoin
+// are put into the same group. Entries keep their original numbers.
+func GetContextGroups(entries []HistoryEntry, matches []int, before int, after int) (groups [][]ContextEntry) {
+	var group []ContextEntry
+	next := 0
+	for matchIdx, idx := range matches {
+		start := idx - before
+		if start < next {
+			start = next
+		}
+		if start < 0 {
+			start = 0
+		}
+		if start > next && group != nil {
+			groups = append(groups, group)
+			group = nil
+		}
+
+		finish := idx + after + 1
+		if matchIdx+1 < len(matches) && finish > matches[matchIdx+1] {
+			finish = matches[matchIdx+1]
+		}
+		if finish > len(entries) {
+			finish = len(entries)
+		}
+
+		for current := start; current < finish; current++ {
+			group = append(group, ContextEntry{HistoryEntry: entries[current], matched: current == idx})
+		}
+		next = finish
+	}
+	if group != nil {
+		groups = append(groups, group)
+	}
+
+	return
+}
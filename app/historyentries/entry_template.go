@@ -0,0 +1,260 @@
+package historyentries
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/9seconds/ah/app/environments"
+	"github.com/9seconds/ah/app/traces"
+	"github.com/9seconds/ah/app/utils"
+)
+
+var (
+	templateUnescaper = strings.NewReplacer(`\t`, "\t", `\n`, "\n", `\\`, `\`)
+
+	templateFunctions = template.FuncMap{
+		"ago":      formatAgo,
+		"truncate": truncate,
+		"size":     utils.FormatSize,
+	}
+)
+
+// EntryTemplate renders history entries with user defined text/template.
+type EntryTemplate struct {
+	template *template.Template
+}
+
+// templateEntry is the data passed to the template. Its methods are
+// accessible from the template.
+type templateEntry struct {
+	entry          HistoryEntry
+	env            *environments.Environment
+	showDuration   bool
+	count          int
+	firstTimestamp int64
+	score          int
+	positions      []int
+	highlight      bool
+}
+
+// NewEntryTemplate compiles the template of history entries. \t and \n
+// sequences are unescaped so templates may be passed from command line.
+func NewEntryTemplate(text string) (*EntryTemplate, error) {
+	compiled, err := template.New("entry").Funcs(templateFunctions).Parse(templateUnescaper.Replace(text))
+	if err != nil {
+		return nil, fmt.Errorf("Cannot parse template: %v", err)
+	}
+
+	return &EntryTemplate{template: compiled}, nil
+}
+
+// Render renders the history entry. If showDuration is set, duration of the
+// command is available for the template.
+func (et *EntryTemplate) Render(entry HistoryEntry, env *environments.Environment, showDuration bool) (string, error) {
+	return et.execute(&templateEntry{
+		entry:          entry,
+		env:            env,
+		showDuration:   showDuration,
+		count:          1,
+		firstTimestamp: entry.timestamp,
+	})
+}
+
+// RenderUnique renders the collapsed history entry.
+func (et *EntryTemplate) RenderUnique(entry UniqueEntry, env *environments.Environment, showDuration bool) (string, error) {
+	return et.execute(&templateEntry{
+		entry:          entry.HistoryEntry,
+		env:            env,
+		showDuration:   showDuration,
+		count:          entry.count,
+		firstTimestamp: entry.firstTimestamp,
+	})
+}
+
+// RenderScored renders the entry matched by fuzzy search.
+func (et *EntryTemplate) RenderScored(entry ScoredEntry, env *environments.Environment, showDuration bool) (string, error) {
+	return et.execute(&templateEntry{
+		entry:          entry.HistoryEntry,
+		env:            env,
+		showDuration:   showDuration,
+		count:          entry.count,
+		firstTimestamp: entry.firstTimestamp,
+		score:          entry.score,
+		positions:      entry.positions,
+		highlight:      entry.highlight,
+	})
+}
+
+func (et *EntryTemplate) execute(data *templateEntry) (string, error) {
+	buffer := new(bytes.Buffer)
+	if err := et.template.Execute(buffer, data); err != nil {
+		return "", fmt.Errorf("Cannot render template: %v", err)
+	}
+
+	return buffer.String(), nil
+}
+
+func (te *templateEntry) Number() uint {
+	return te.entry.number
+}
+
+func (te *templateEntry) Reference() string {
+	return te.entry.GetReference()
+}
+
+func (te *templateEntry) Source() string {
+	return te.entry.source
+}
+
+func (te *templateEntry) Command() string {
+	return te.entry.command
+}
+
+// Highlighted returns the command with characters matched by fuzzy search
+// highlighted. It is the same as Command if highlighting is not enabled.
+func (te *templateEntry) Highlighted() string {
+	return highlightCommand(te.entry.command, te.positions, te.highlight)
+}
+
+// Score returns the score of fuzzy search, it is 0 if fuzzy search is not used.
+func (te *templateEntry) Score() int {
+	return te.score
+}
+
+func (te *templateEntry) Timestamp() int64 {
+	return te.entry.timestamp
+}
+
+// Time formats the time of the command with Go layout.
+func (te *templateEntry) Time(layout string) string {
+	return te.entry.GetTime().Format(layout)
+}
+
+// FormattedTime formats the time of the command according to the
+// HISTTIMEFORMAT setting. It is empty if HISTTIMEFORMAT is not set.
+func (te *templateEntry) FormattedTime() string {
+	return te.entry.GetFormattedTime(te.env)
+}
+
+func (te *templateEntry) FirstTimestamp() int64 {
+	return te.firstTimestamp
+}
+
+// FirstTime formats the time of the first occurrence of the command with Go layout.
+func (te *templateEntry) FirstTime(layout string) string {
+	return utils.ConvertTimestamp(te.firstTimestamp).Format(layout)
+}
+
+// FormattedFirstTime formats the time of the first occurrence of the
+// command according to the HISTTIMEFORMAT setting.
+func (te *templateEntry) FormattedFirstTime() string {
+	return te.env.FormatTimeStamp(te.firstTimestamp)
+}
+
+func (te *templateEntry) Count() int {
+	return te.count
+}
+
+func (te *templateEntry) ShowDuration() bool {
+	return te.showDuration
+}
+
+func (te *templateEntry) HasDuration() bool {
+	return te.entry.HasDuration()
+}
+
+func (te *templateEntry) Duration() time.Duration {
+	return te.entry.GetDuration()
+}
+
+func (te *templateEntry) HasTrace() bool {
+	return te.entry.hasHistory
+}
+
+func (te *templateEntry) TraceSize() int64 {
+	return te.entry.traceSize
+}
+
+// TraceHeader returns the metadata of the stored output. It is nil if
+// output is not stored or it was stored by old version of ah.
+func (te *templateEntry) TraceHeader() *traces.Header {
+	return te.entry.traceHeader
+}
+
+// HasRecord tells if command has a metadata recorded by shell hooks.
+func (te *templateEntry) HasRecord() bool {
+	return te.entry.record != nil
+}
+
+// Directory returns a directory where command was executed. It is empty
+// if command was not recorded.
+func (te *templateEntry) Directory() string {
+	if te.entry.record == nil {
+		return ""
+	}
+	return te.entry.record.Directory
+}
+
+// ShortDirectory returns the directory with home directory replaced by ~.
+func (te *templateEntry) ShortDirectory() string {
+	if te.entry.record == nil {
+		return ""
+	}
+	return te.entry.record.GetShortDirectory(te.env)
+}
+
+// ExitStatus returns the exit status of the command, it is 0 if command
+// was not recorded.
+func (te *templateEntry) ExitStatus() int {
+	if te.entry.record == nil {
+		return 0
+	}
+	return te.entry.record.ExitStatus
+}
+
+func (te *templateEntry) Host() string {
+	if te.entry.record == nil {
+		return ""
+	}
+	return te.entry.record.Host
+}
+
+func (te *templateEntry) TTY() string {
+	if te.entry.record == nil {
+		return ""
+	}
+	return te.entry.record.TTY
+}
+
+// formatAgo formats the timestamp relatively to the current time, e.g
+// "5m ago".
+func formatAgo(timestamp int64) string {
+	seconds := environments.CreatedAt - timestamp
+	switch {
+	case seconds < 0:
+		return "in future"
+	case seconds < 60:
+		return fmt.Sprintf("%ds ago", seconds)
+	case seconds < 60*60:
+		return fmt.Sprintf("%dm ago", seconds/60)
+	case seconds < 60*60*24:
+		return fmt.Sprintf("%dh ago", seconds/(60*60))
+	}
+	return fmt.Sprintf("%dd ago", seconds/(60*60*24))
+}
+
+// truncate cuts the text to the given number of characters. Argument order
+// allows to use it in pipelines: {{.Command | truncate 20}}.
+func truncate(length int, text string) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+	if length <= 3 {
+		return string(runes[:length])
+	}
+	return string(runes[:length-3]) + "..."
+}
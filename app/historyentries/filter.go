@@ -0,0 +1,76 @@
+package historyentries
+
+import (
+	"github.com/9seconds/ah/app/utils"
+)
+
+// Filter selects history entries. Unlike conditions, filters are applied by
+// parsers also.
+type Filter interface {
+	// Match checks if the entry passes the filter.
+	Match(*HistoryEntry) bool
+	// MatchCommand checks if the entry with given command may pass the
+	// filter. Parsers use it while the rest of the entry is not known yet.
+	MatchCommand(string) bool
+}
+
+type matcherFilter struct {
+	matcher utils.Matcher
+}
+
+type joinedFilter []Filter
+
+// MatcherFilter returns a filter which matches command lines with the
+// matcher.
+func MatcherFilter(matcher utils.Matcher) Filter {
+	return &matcherFilter{matcher: matcher}
+}
+
+// JoinFilters returns a filter which passes entries passed by all given
+// filters. nil filters are skipped, nil is returned if there are no filters.
+func JoinFilters(filters ...Filter) Filter {
+	var joined joinedFilter
+	for _, filter := range filters {
+		if filter != nil {
+			joined = append(joined, filter)
+		}
+	}
+
+	switch len(joined) {
+	case 0:
+		return nil
+	case 1:
+		return joined[0]
+	}
+	return joined
+}
+
+func (mf *matcherFilter) Match(entry *HistoryEntry) bool {
+	return mf.matcher.Match(entry.command)
+}
+
+func (mf *matcherFilter) MatchCommand(command string) bool {
+	return mf.matcher.Match(command)
+}
+
+func (jf joinedFilter) Match(entry *HistoryEntry) bool {
+	for _, filter := range jf {
+		if !filter.Match(entry) {
+			return false
+		}
+	}
+	return true
+}
+
+func (jf joinedFilter) MatchCommand(command string) bool {
+	for _, filter := range jf {
+		if !filter.MatchCommand(command) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchFilter(entry *HistoryEntry, filter Filter) bool {
+	return filter == nil || filter.Match(entry)
+}
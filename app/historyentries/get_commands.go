@@ -1,10 +1,7 @@
 package historyentries
 
 import (
-	"bufio"
-
 	"github.com/9seconds/ah/app/environments"
-	"github.com/9seconds/ah/app/utils"
 )
 
 // GetCommandsMode defines the mode GetCommands has to work.
@@ -16,29 +13,65 @@ const (
 	GetCommandsRange
 	GetCommandsSingle
 	GetCommandsPrecise
+	GetCommandsLast
+	GetCommandsRecent
 )
 
-// GetCommands returns a keeper for the commands based on given mode and regular expression.
+// GetCommands returns a keeper for the commands based on given mode, filter and conditions.
 // varargs is the auxiliary list of numbers which makes sense in the context of GetCommandsMode setting
-// only.
-func GetCommands(mode GetCommandsMode, filter *utils.Regexp, env *environments.Environment, varargs ...int) (commands Keeper, err error) {
+// only: GetCommandsLast takes the number of the latest commands, GetCommandsRecent takes the timestamp
+// and returns the commands executed after it (but the latest command anyway).
+func GetCommands(mode GetCommandsMode, filter Filter, conditions []Condition, env *environments.Environment, varargs ...int) (commands Keeper, err error) {
+	entries, err := getEntries(mode, filter, conditions, env, varargs...)
+	if err != nil {
+		return
+	}
+
 	keeper := getKeeper(mode, varargs...)
+	if ak, ok := keeper.(*allKeeper); ok {
+		ak.Reserve(len(entries) + 1)
+	}
 	resultChan, consumeChan := processHistories(env)
-	parser := getParser(env)
 
-	histFileName, err := env.GetHistFileName()
-	if err != nil {
-		return
+	commands = feedKeeper(getConditionalKeeper(keeper, conditions), entries, filter, consumeChan)
+	<-resultChan
+
+	return
+}
+
+// getEntries reads the entries required by the mode. Modes which need the
+// latest entries only read history file backwards.
+func getEntries(mode GetCommandsMode, filter Filter, conditions []Condition, env *environments.Environment, varargs ...int) ([]HistoryEntry, error) {
+	switch mode {
+	case GetCommandsLast:
+		return getLastEntries(env, filter, conditions, func(entries []HistoryEntry, _ *HistoryEntry) bool {
+			return len(entries) >= varargs[0]
+		})
+	case GetCommandsRecent:
+		return getLastEntries(env, filter, conditions, func(entries []HistoryEntry, entry *HistoryEntry) bool {
+			return len(entries) > 0 && entry.timestamp < int64(varargs[0])
+		})
+	case GetCommandsPrecise:
+		return getLastEntries(env, nil, nil, func(entries []HistoryEntry, _ *HistoryEntry) bool {
+			return len(entries) > 0 && entries[len(entries)-1].number <= uint(varargs[0])
+		})
 	}
 
-	file := utils.Open(histFileName)
-	defer file.Close()
-	scanner := bufio.NewScanner(file)
+	return getIndexedEntries(env)
+}
+
+// feedKeeper commits parsed entries into the keeper until it wants more.
+func feedKeeper(keeper Keeper, entries []HistoryEntry, filter Filter, historyChan chan *HistoryEntry) Keeper {
+	defer close(historyChan)
 
-	commands, err = parser(keeper, scanner, filter, consumeChan)
-	if err == nil {
-		<-resultChan
-		return commands, nil
+	current := keeper.Init()
+	for idx := 0; idx < len(entries) && keeper.Continue(); idx++ {
+		if !matchFilter(&entries[idx], filter) {
+			continue
+		}
+		*current = entries[idx]
+		current = keeper.Commit(current, historyChan)
 	}
-	return
+
+	return keeper
 }
@@ -5,23 +5,31 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"strconv"
 	"time"
 
 	"github.com/9seconds/ah/app/environments"
+	"github.com/9seconds/ah/app/traces"
 	"github.com/9seconds/ah/app/utils"
 )
 
 const (
-	markHasHistory   = '*'
-	markHasNoHistory = ' '
+	markHasHistory   = "*"
+	markHasNoHistory = " "
 )
 
 // HistoryEntry stores a command with its context.
 type HistoryEntry struct {
-	number     uint
-	command    string
-	timestamp  int64
-	hasHistory bool
+	source      string
+	number      uint
+	command     string
+	timestamp   int64
+	elapsed     int64
+	hasElapsed  bool
+	hasHistory  bool
+	traceSize   int64
+	traceHeader *traces.Header
+	record      *EntryRecord
 }
 
 // GetNumber returns a history number (may be executed with ! later).
@@ -29,6 +37,22 @@ func (he HistoryEntry) GetNumber() uint {
 	return he.number
 }
 
+// GetSource returns a label of the history source the entry was read from.
+// It is empty if there is the only history source.
+func (he HistoryEntry) GetSource() string {
+	return he.source
+}
+
+// GetReference returns a reference to the entry which may be used to
+// fetch it later: a history number prefixed with the source label if any.
+func (he HistoryEntry) GetReference() string {
+	reference := strconv.FormatUint(uint64(he.number), 10)
+	if he.source != "" {
+		reference = he.source + referenceSeparator + reference
+	}
+	return reference
+}
+
 // GetCommand returns a command line which was executed.
 func (he HistoryEntry) GetCommand() string {
 	return he.command
@@ -49,31 +73,98 @@ func (he HistoryEntry) GetFormattedTime(env *environments.Environment) string {
 	return env.FormatTimeStamp(he.timestamp)
 }
 
+// GetDuration returns how long the command was executed. If shell does
+// not store such information, duration recorded by shell hooks is used.
+// Zero if it is unknown.
+func (he HistoryEntry) GetDuration() time.Duration {
+	if !he.hasElapsed && he.record != nil {
+		return time.Duration(he.record.GetDuration()) * time.Second
+	}
+	return time.Duration(he.elapsed) * time.Second
+}
+
+// HasDuration tells if shell or shell hooks have stored a duration of the
+// command.
+func (he HistoryEntry) HasDuration() bool {
+	return he.hasElapsed || (he.record != nil && he.record.HasDuration())
+}
+
 // HasHistory tells if history entry has a trace stored.
 func (he HistoryEntry) HasHistory() bool {
 	return he.hasHistory
 }
 
+// GetTraceSize returns a size of the stored trace in bytes.
+func (he HistoryEntry) GetTraceSize() int64 {
+	return he.traceSize
+}
+
+// GetRecord returns a metadata recorded by shell hooks or nil if command
+// was not recorded.
+func (he HistoryEntry) GetRecord() *EntryRecord {
+	return he.record
+}
+
 // String makes a string representation of the structure
 func (he HistoryEntry) String() string {
 	timestamp := utils.ConvertTimestamp(he.timestamp).Format(time.RFC3339)
-	return fmt.Sprintf("HistoryEntry{number=%d command=\"%s\" timestamp=\"%s\" hasHistory=%t}",
-		he.number, he.command, timestamp, he.hasHistory)
+	return fmt.Sprintf("HistoryEntry{source=\"%s\" number=%d command=\"%s\" timestamp=\"%s\" elapsed=%d hasHistory=%t}",
+		he.source, he.number, he.command, timestamp, he.elapsed, he.hasHistory)
 }
 
 // ToString converts history entry to the string representation according to the environment setting.
-func (he HistoryEntry) ToString(env *environments.Environment) string {
-	timestamp := ""
-	if formattedTimestamp := env.FormatTimeStamp(he.timestamp); formattedTimestamp != "" {
-		timestamp = "  (" + formattedTimestamp + ")"
+// If showDuration is set, duration of the command is rendered (if known). Default layout is rendered
+// without templates because it is much faster on large histories.
+func (he HistoryEntry) ToString(env *environments.Environment, showDuration bool) string {
+	return he.formatLine(env, showDuration, "", env.FormatTimeStamp(he.timestamp), he.getPrompt(env)+he.command)
+}
+
+// formatLine renders the default layout of the entry. Counter goes after the
+// reference, formatted timestamp may be empty and text is the rest of the line.
+func (he HistoryEntry) formatLine(env *environments.Environment, showDuration bool, counter string,
+	timestamp string, text string) string {
+	if timestamp != "" {
+		timestamp = "  (" + timestamp + ")"
+	}
+	if showDuration && he.HasDuration() {
+		timestamp += "  [" + he.GetDuration().String() + "]"
 	}
 
 	history := markHasNoHistory
 	if he.hasHistory {
-		history = markHasHistory
+		history = markHasHistory + he.getTraceInfo()
+	}
+
+	return fmt.Sprintf("!%-5s%s%s %s  %s", he.GetReference(), counter, timestamp, history, text)
+}
+
+// getPrompt returns exit status and directory of the command recorded by
+// shell hooks like "[1] ~/project$ ". It is empty if command was not recorded.
+func (he HistoryEntry) getPrompt(env *environments.Environment) string {
+	if he.record == nil {
+		return ""
 	}
 
-	return fmt.Sprintf("!%-5d%s %c  %s", he.number, timestamp, history, he.command)
+	prompt := he.record.GetShortDirectory(env) + "$ "
+	if he.record.ExitStatus != 0 {
+		prompt = fmt.Sprintf("[%d] %s", he.record.ExitStatus, prompt)
+	}
+	return prompt
+}
+
+// getTraceInfo returns exit status (or signal) and size of the stored output
+// like " [0, 1.5 KiB]". It is empty if trace has no header.
+func (he HistoryEntry) getTraceInfo() string {
+	header := he.traceHeader
+	if header == nil {
+		return ""
+	}
+
+	status := header.Signal
+	if status == "" {
+		status = strconv.Itoa(header.ExitStatus)
+	}
+	return " [" + status + ", " + utils.FormatSize(header.Size) + "]"
 }
 
 // GetTraceName returns a trace name of the history entry.
@@ -0,0 +1,130 @@
+package historyentries
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/9seconds/ah/app/environments"
+	"github.com/9seconds/ah/app/traces"
+)
+
+// Templates which render the same layout as ToString does.
+const (
+	defaultEntryLayout = `!{{printf "%-5s" .Reference}}` +
+		`{{with .FormattedTime}}  ({{.}}){{end}}` +
+		`{{if and .ShowDuration .HasDuration}}  [{{.Duration}}]{{end}}` +
+		` {{if .HasTrace}}*` + traceInfoLayout + `{{else}} {{end}}  ` +
+		`{{if .HasRecord}}{{with .ExitStatus}}[{{.}}] {{end}}{{.ShortDirectory}}$ {{end}}{{.Highlighted}}`
+
+	defaultUniqueLayout = `!{{printf "%-5s" .Reference}} {{printf "%4d" .Count}}x` +
+		`{{with .FormattedTime}}  ({{with $.FormattedFirstTime}}{{if ne . $.FormattedTime}}{{.}} - {{end}}{{end}}{{.}}){{end}}` +
+		`{{if and .ShowDuration .HasDuration}}  [{{.Duration}}]{{end}}` +
+		` {{if .HasTrace}}*` + traceInfoLayout + `{{else}} {{end}}  {{.Highlighted}}`
+
+	traceInfoLayout = `{{with .TraceHeader}} [{{with .Signal}}{{.}}{{else}}{{.ExitStatus}}{{end}}, {{size .Size}}]{{end}}`
+)
+
+func TestToString(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ah")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	env := &environments.Environment{HistTimeFormat: "%Y", HomeDir: "/home/user", TracesDir: dir}
+	traced := HistoryEntry{number: 12, command: "make", timestamp: 1430000000, hasHistory: true}
+	writer, err := traces.NewWriter(dir, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Stream(traces.StreamStdout).Write([]byte("output"))
+	if err = writer.Save(env.GetTraceFileName(traced.GetTraceName()), &traces.Header{ExitStatus: 2}); err != nil {
+		t.Fatal(err)
+	}
+	writer.Close()
+
+	resultChan, consumeChan := processHistories(env)
+	consumeChan <- &traced
+	close(consumeChan)
+	<-resultChan
+
+	entries := []struct {
+		entry    HistoryEntry
+		expected string
+	}{
+		{
+			entry:    HistoryEntry{number: 5, command: "ls", timestamp: 1430000000},
+			expected: "!5      (2015)    ls",
+		},
+		{
+			entry:    HistoryEntry{number: 123456, command: "ls", source: "zsh", timestamp: 1430000000},
+			expected: "!zsh:123456  (2015)    ls",
+		},
+		{
+			entry:    HistoryEntry{number: 7, command: "sleep 3", timestamp: 1430000000, elapsed: 3, hasElapsed: true},
+			expected: "!7      (2015)  [3s]    sleep 3",
+		},
+		{
+			entry: HistoryEntry{number: 8, command: "false", timestamp: 1430000000,
+				record: &EntryRecord{Directory: "/home/user/project", ExitStatus: 1, Started: 10, Finished: 12}},
+			expected: "!8      (2015)  [2s]    [1] ~/project$ false",
+		},
+		{
+			entry: HistoryEntry{number: 9, command: "true", timestamp: 1430000000,
+				record: &EntryRecord{Directory: "/tmp"}},
+			expected: "!9      (2015)    /tmp$ true",
+		},
+		{
+			entry:    HistoryEntry{number: 10, command: "legacy", timestamp: 1430000000, hasHistory: true},
+			expected: "!10     (2015) *  legacy",
+		},
+		{
+			entry:    traced,
+			expected: "!12     (2015) * [2, 6 B]  make",
+		},
+	}
+
+	entryTemplate, err := NewEntryTemplate(defaultEntryLayout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uniqueTemplate, err := NewEntryTemplate(defaultUniqueLayout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, testCase := range entries {
+		actual := testCase.entry.ToString(env, true)
+		if actual != testCase.expected {
+			t.Errorf("ToString is %q, expected %q", actual, testCase.expected)
+		}
+
+		for _, showDuration := range []bool{false, true} {
+			entry := testCase.entry
+			rendered, _ := entryTemplate.Render(entry, env, showDuration)
+			if actual = entry.ToString(env, showDuration); actual != rendered {
+				t.Errorf("ToString is %q, template renders %q", actual, rendered)
+			}
+
+			unique := UniqueEntry{HistoryEntry: entry, count: 3, firstTimestamp: 1400000000}
+			rendered, _ = uniqueTemplate.RenderUnique(unique, env, showDuration)
+			if actual = unique.ToString(env, showDuration); actual != rendered {
+				t.Errorf("Unique ToString is %q, template renders %q", actual, rendered)
+			}
+
+			for _, collapsed := range []bool{false, true} {
+				scored := ScoredEntry{UniqueEntry: unique, positions: []int{0, 1}, collapsed: collapsed, highlight: true}
+				layout := entryTemplate
+				if collapsed {
+					layout = uniqueTemplate
+				}
+				rendered, _ = layout.RenderScored(scored, env, showDuration)
+				if actual = scored.ToString(env, showDuration); actual != rendered {
+					t.Errorf("Scored ToString is %q, template renders %q", actual, rendered)
+				}
+			}
+		}
+	}
+}
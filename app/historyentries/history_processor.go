@@ -4,6 +4,7 @@ import (
 	logrus "github.com/Sirupsen/logrus"
 
 	"github.com/9seconds/ah/app/environments"
+	"github.com/9seconds/ah/app/traces"
 	"github.com/9seconds/ah/app/utils"
 )
 
@@ -12,7 +13,15 @@ func processHistories(env *environments.Environment) (resultChan chan bool, cons
 	consumeChan = make(chan *HistoryEntry, historyEventsCapacity)
 
 	go func() {
-		entries := make(map[string]bool)
+		entries := make(map[string]int64)
+		headers := make(map[string]*traces.Header)
+
+		records, err := LoadRecords(env)
+		if err != nil {
+			utils.Logger.WithFields(logrus.Fields{
+				"error": err,
+			}).Warn("Error on records reading")
+		}
 
 		files, err := env.GetTracesFileInfos()
 		if err != nil {
@@ -24,17 +33,39 @@ func processHistories(env *environments.Environment) (resultChan chan bool, cons
 		}
 
 		for _, file := range files {
-			entries[file.Name()] = true
+			entries[file.Name()] = file.Size()
 		}
 		utils.Logger.WithField("filenames", entries).Info("Parsed filenames")
 
 		for entry := range consumeChan {
-			if _, found := entries[entry.GetTraceName()]; found {
+			traceName := entry.GetTraceName()
+			if size, found := entries[traceName]; found {
+				header, read := headers[traceName]
+				if !read {
+					header = readTraceHeader(env, traceName)
+					headers[traceName] = header
+				}
 				entry.hasHistory = true
+				entry.traceSize = size
+				entry.traceHeader = header
 			}
+			entry.record = records[traceName]
 		}
 		resultChan <- true
 	}()
 
 	return
 }
+
+// readTraceHeader returns the metadata of the stored output. It is nil if
+// output was stored by old version of ah or header cannot be read.
+func readTraceHeader(env *environments.Environment, traceName string) *traces.Header {
+	header, err := traces.ReadHeader(env.GetTraceFileName(traceName))
+	if err != nil {
+		utils.Logger.WithFields(logrus.Fields{
+			"trace": traceName,
+			"error": err,
+		}).Warn("Cannot read a header of the trace")
+	}
+	return header
+}
@@ -0,0 +1,312 @@
+package historyentries
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	logrus "github.com/Sirupsen/logrus"
+
+	"github.com/9seconds/ah/app/environments"
+	"github.com/9seconds/ah/app/utils"
+)
+
+const (
+	// indexVersion has to be incremented on any change of the index format
+	// or parsing logic. Indexes of other versions are rebuilt.
+	indexVersion = 5
+
+	// indexSignatureLength is the number of bytes before the indexed offset
+	// which are checked to detect that history file was rewritten.
+	indexSignatureLength = 64
+)
+
+// indexedEntry is a serializable version of HistoryEntry.
+type indexedEntry struct {
+	Number     uint
+	Command    string
+	Timestamp  int64
+	Elapsed    int64
+	HasElapsed bool
+}
+
+// indexHeader describes the indexed part of the history file: entries
+// parsed from the first Offset bytes of the file with given inode. If file
+// grows, only the appended tail is parsed. Header is stored before the
+// entries so it may be read without decoding them.
+type indexHeader struct {
+	Version    int
+	Inode      uint64
+	Offset     int64
+	Signature  []byte
+	NextNumber uint
+}
+
+// historyFile is an opened history file with its index.
+type historyFile struct {
+	env       *environments.Environment
+	shell     *Shell
+	file      *os.File
+	size      int64
+	inode     uint64
+	indexName string
+}
+
+func openHistoryFile(env *environments.Environment) (*historyFile, error) {
+	shell, err := getShell(env)
+	if err != nil {
+		return nil, err
+	}
+	histFileName, err := GetHistFileName(env)
+	if err != nil {
+		return nil, err
+	}
+	if absFileName, err := filepath.Abs(histFileName); err == nil {
+		histFileName = absFileName
+	}
+
+	file := utils.Open(histFileName)
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &historyFile{
+		env:       env,
+		shell:     shell,
+		file:      file,
+		size:      stat.Size(),
+		inode:     getInode(stat),
+		indexName: env.GetIndexFileName(getIndexKey(histFileName, env)),
+	}, nil
+}
+
+// getIndexedEntries returns all entries of the history file. It updates the
+// index with the appended tail or rebuilds it if history file was truncated,
+// rotated or rewritten.
+func getIndexedEntries(env *environments.Environment) ([]HistoryEntry, error) {
+	history, err := openHistoryFile(env)
+	if err != nil {
+		return nil, err
+	}
+	defer history.Close()
+
+	return history.updateIndex()
+}
+
+func (hf *historyFile) Close() error {
+	return hf.file.Close()
+}
+
+func (hf *historyFile) updateIndex() ([]HistoryEntry, error) {
+	header, entries := hf.loadIndex()
+	if !hf.isValidIndex(header) {
+		utils.Logger.WithFields(logrus.Fields{
+			"histfile": hf.file.Name(),
+			"index":    hf.indexName,
+		}).Info("Index is outdated, rebuild it")
+		header = &indexHeader{Version: indexVersion, Inode: hf.inode, NextNumber: hf.shell.NumberBase}
+		entries = nil
+	}
+	if header.Offset == hf.size {
+		return entries, nil
+	}
+
+	tail, context, err := hf.parseTail(header)
+	if err != nil {
+		return nil, err
+	}
+
+	// commands after the checkpoint may be incomplete so they are parsed
+	// again next time.
+	if context.checkpoint.offset > 0 {
+		header.Offset += context.checkpoint.offset
+		header.NextNumber = context.checkpoint.number
+		header.Signature = hf.readSignature(header.Offset)
+		hf.saveIndex(header, append(entries, tail[:context.checkpoint.commits]...))
+	}
+
+	return append(entries, tail...), nil
+}
+
+// parseTail parses the part of the history file which is not indexed yet.
+func (hf *historyFile) parseTail(header *indexHeader) ([]HistoryEntry, *ParseContext, error) {
+	tail := make([]byte, hf.size-header.Offset)
+	if _, err := hf.file.ReadAt(tail, header.Offset); err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+
+	keeper := new(collectKeeper)
+	context, err := parseHistory(hf.newParser(header.Offset), keeper, bytes.NewReader(tail), nil,
+		make(chan *HistoryEntry), header.NextNumber)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	utils.Logger.WithFields(logrus.Fields{
+		"offset":     header.Offset,
+		"tail":       len(tail),
+		"entries":    len(keeper.entries),
+		"checkpoint": context.checkpoint.offset,
+	}).Info("Parse tail of the history file")
+
+	return keeper.entries, context, nil
+}
+
+// newParser returns a parser which starts from the given offset of the
+// history file.
+func (hf *historyFile) newParser(offset int64) ShellSpecificParser {
+	parser := hf.shell.NewParser(hf.env)
+	if detector, ok := parser.(formatDetector); ok && offset > 0 {
+		detector.DetectFormat(hf.readHeader())
+	}
+
+	return parser
+}
+
+func (hf *historyFile) isValidIndex(header *indexHeader) bool {
+	return header.Version == indexVersion &&
+		header.Inode == hf.inode &&
+		header.Offset <= hf.size &&
+		bytes.Equal(header.Signature, hf.readSignature(header.Offset))
+}
+
+// loadIndexHeader reads only the header of the index.
+func (hf *historyFile) loadIndexHeader() *indexHeader {
+	header := new(indexHeader)
+
+	file, err := os.Open(hf.indexName)
+	if err != nil {
+		utils.Logger.WithField("error", err).Info("Cannot open history index")
+		return header
+	}
+	defer file.Close()
+
+	if err = gob.NewDecoder(bufio.NewReader(file)).Decode(header); err != nil {
+		utils.Logger.WithField("error", err).Warn("Cannot decode history index")
+		return new(indexHeader)
+	}
+
+	return header
+}
+
+func (hf *historyFile) loadIndex() (*indexHeader, []HistoryEntry) {
+	header := new(indexHeader)
+	var indexed []indexedEntry
+
+	file, err := os.Open(hf.indexName)
+	if err != nil {
+		utils.Logger.WithField("error", err).Info("Cannot open history index")
+		return header, nil
+	}
+	defer file.Close()
+
+	decoder := gob.NewDecoder(bufio.NewReader(file))
+	if err = decoder.Decode(header); err == nil {
+		err = decoder.Decode(&indexed)
+	}
+	if err != nil {
+		utils.Logger.WithField("error", err).Warn("Cannot decode history index")
+		return new(indexHeader), nil
+	}
+
+	entries := make([]HistoryEntry, len(indexed))
+	for idx, entry := range indexed {
+		entries[idx] = HistoryEntry{
+			number:     entry.Number,
+			command:    entry.Command,
+			timestamp:  entry.Timestamp,
+			elapsed:    entry.Elapsed,
+			hasElapsed: entry.HasElapsed,
+		}
+	}
+
+	return header, entries
+}
+
+// saveIndex writes index into temporary file and renames it so concurrent
+// readers always see the complete index.
+func (hf *historyFile) saveIndex(header *indexHeader, entries []HistoryEntry) {
+	indexed := make([]indexedEntry, len(entries))
+	for idx, entry := range entries {
+		indexed[idx] = indexedEntry{
+			Number:     entry.number,
+			Command:    entry.command,
+			Timestamp:  entry.timestamp,
+			Elapsed:    entry.elapsed,
+			HasElapsed: entry.hasElapsed,
+		}
+	}
+
+	file, err := ioutil.TempFile(hf.env.IndexDir, "tmp")
+	if err != nil {
+		utils.Logger.WithField("error", err).Warn("Cannot create history index")
+		return
+	}
+
+	buffer := bufio.NewWriter(file)
+	encoder := gob.NewEncoder(buffer)
+	err = encoder.Encode(header)
+	if err == nil {
+		err = encoder.Encode(indexed)
+	}
+	if err == nil {
+		err = buffer.Flush()
+	}
+	file.Close()
+
+	if err == nil {
+		err = os.Rename(file.Name(), hf.indexName)
+	}
+	if err != nil {
+		utils.Logger.WithField("error", err).Warn("Cannot save history index")
+		os.Remove(file.Name())
+	}
+}
+
+func (hf *historyFile) readSignature(offset int64) []byte {
+	start := offset - indexSignatureLength
+	if start < 0 {
+		start = 0
+	}
+
+	signature := make([]byte, offset-start)
+	if _, err := hf.file.ReadAt(signature, start); err != nil && err != io.EOF {
+		return nil
+	}
+
+	return signature
+}
+
+func (hf *historyFile) readHeader() []byte {
+	header := make([]byte, indexSignatureLength)
+	length, _ := hf.file.ReadAt(header, 0)
+
+	return header[:length]
+}
+
+// getIndexKey returns a name of the index file. Parsing depends on the
+// shell and its settings so they are the part of the key.
+func getIndexKey(histFileName string, env *environments.Environment) string {
+	digest := md5.New()
+	io.WriteString(digest, histFileName)
+	io.WriteString(digest, "\x00"+env.Shell)
+	io.WriteString(digest, "\x00"+env.BashRecords)
+
+	return fmt.Sprintf("%x", digest.Sum(nil))
+}
+
+func getInode(stat os.FileInfo) uint64 {
+	if sysStat, ok := stat.Sys().(*syscall.Stat_t); ok {
+		return uint64(sysStat.Ino)
+	}
+	return 0
+}
@@ -0,0 +1,87 @@
+package historyentries
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/9seconds/ah/app/environments"
+)
+
+func TestIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ah")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	env := &environments.Environment{
+		Shell:    environments.ShellZsh,
+		HistFile: filepath.Join(dir, ".zsh_history"),
+		IndexDir: dir,
+	}
+	steps := []struct {
+		name     string
+		history  string
+		append   bool
+		expected []expectedEntry
+		offset   int64
+	}{
+		{
+			name:     "new",
+			history:  ": 1430000000:0;ls\n: 1430000001:0;pwd",
+			expected: []expectedEntry{{1, "ls", 1430000000}, {2, "pwd", 1430000001}},
+			offset:   18,
+		},
+		{
+			name:    "appended",
+			history: "\n: 1430000002:0;make\n",
+			append:  true,
+			expected: []expectedEntry{
+				{1, "ls", 1430000000},
+				{2, "pwd", 1430000001},
+				{3, "make", 1430000002},
+			},
+			offset: 57,
+		},
+		{
+			name:     "rewritten",
+			history:  ": 1430000003:0;top\n",
+			expected: []expectedEntry{{1, "top", 1430000003}},
+			offset:   19,
+		},
+	}
+
+	for _, step := range steps {
+		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if step.append {
+			flags = os.O_WRONLY | os.O_APPEND
+		}
+		file, err := os.OpenFile(env.HistFile, flags, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.WriteString(step.history)
+		file.Close()
+
+		entries, err := getIndexedEntries(env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkEntries(t, step.name, entries, step.expected)
+
+		history, err := openHistoryFile(env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		header, indexed := history.loadIndex()
+		history.Close()
+		if header.Offset != step.offset {
+			t.Errorf("%s: index offset is %d, expected %d", step.name, header.Offset, step.offset)
+		}
+		if header.NextNumber != uint(len(indexed))+1 {
+			t.Errorf("%s: next number is %d for %d indexed entries", step.name, header.NextNumber, len(indexed))
+		}
+	}
+}
@@ -38,6 +38,7 @@ type preciseNumberKeeper struct {
 
 type allKeeper struct {
 	currentIndex int
+	capacity     int
 	entries      []HistoryEntry
 }
 
@@ -49,6 +50,18 @@ type rangeKeeper struct {
 	entries      []HistoryEntry
 }
 
+// collectKeeper collects all entries without passing them to consumers.
+// It is used to build the history index.
+type collectKeeper struct {
+	current HistoryEntry
+	entries []HistoryEntry
+}
+
+type conditionalKeeper struct {
+	Keeper
+	conditions []Condition
+}
+
 func (sk *singleKeeper) Init() *HistoryEntry {
 	sk.current = new(HistoryEntry)
 	return sk.current
@@ -78,8 +91,18 @@ func (pnk *preciseNumberKeeper) SetNumber(number int) {
 	pnk.preciseNumber = uint(number)
 }
 
+// Reserve sets the number of entries keeper has to preallocate. Entries are
+// passed by pointers so reallocation loses the changes made by consumers.
+func (ak *allKeeper) Reserve(capacity int) {
+	ak.capacity = capacity
+}
+
 func (ak *allKeeper) Init() *HistoryEntry {
-	ak.entries = make([]HistoryEntry, historyEventsCapacity)
+	capacity := historyEventsCapacity
+	if ak.capacity > 0 {
+		capacity = ak.capacity
+	}
+	ak.entries = make([]HistoryEntry, capacity)
 	return &ak.entries[0]
 }
 
@@ -129,9 +152,44 @@ func (rk *rangeKeeper) Result() interface{} {
 	return rk.entries[:rk.currentIndex-rk.start]
 }
 
+func (ck *collectKeeper) Init() *HistoryEntry {
+	return &ck.current
+}
+
+func (ck *collectKeeper) Commit(event *HistoryEntry, historyChannel chan *HistoryEntry) *HistoryEntry {
+	ck.entries = append(ck.entries, *event)
+	ck.current = HistoryEntry{}
+	return &ck.current
+}
+
+func (ck *collectKeeper) Continue() bool {
+	return true
+}
+
+func (ck *collectKeeper) Result() interface{} {
+	return ck.entries
+}
+
+// Commit passes the event to the wrapped keeper only if it satisfies all
+// conditions. Otherwise the same event is reused for the next command.
+func (ck *conditionalKeeper) Commit(event *HistoryEntry, historyChannel chan *HistoryEntry) *HistoryEntry {
+	if !matchConditions(event, ck.conditions) {
+		utils.Logger.WithField("event", event).Info("Skip event because of the conditions.")
+		return event
+	}
+	return ck.Keeper.Commit(event, historyChannel)
+}
+
+func getConditionalKeeper(keeper Keeper, conditions []Condition) Keeper {
+	if len(conditions) == 0 {
+		return keeper
+	}
+	return &conditionalKeeper{Keeper: keeper, conditions: conditions}
+}
+
 func getKeeper(mode GetCommandsMode, varargs ...int) Keeper {
 	switch mode {
-	case GetCommandsAll:
+	case GetCommandsAll, GetCommandsLast, GetCommandsRecent:
 		return new(allKeeper)
 	case GetCommandsRange:
 		keeper := new(rangeKeeper)
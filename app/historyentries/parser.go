@@ -2,164 +2,205 @@ package historyentries
 
 import (
 	"bufio"
-	"strconv"
+	"io"
 	"strings"
 
 	logrus "github.com/Sirupsen/logrus"
 
-	"github.com/9seconds/ah/app/environments"
 	"github.com/9seconds/ah/app/utils"
 )
 
-var (
-	bashTimestampRegexp = utils.CreateRegexp(`^#\s*\d+$`)
+type (
+	// ShellSpecificParser implements shell specific logic for parsing. New parser is
+	// created for each history file so it may keep its own state.
+	ShellSpecificParser interface {
+		// Parse processes a line of the history file.
+		Parse(*ParseContext, string)
+		// Finish commits a command which is still pending on the end of the file.
+		Finish(*ParseContext)
+	}
 
-	zshLineRegexp = utils.CreateRegexp(`^: (\d+):\d;(.*?)$`)
+	// lineDecoder is implemented by parsers which have to decode history lines
+	// before parsing.
+	lineDecoder interface {
+		Decode(string) string
+	}
 
-	fishCmdRegexp  = utils.CreateRegexp(`^- cmd:\s*(.*?)$`)
-	fishWhenRegexp = utils.CreateRegexp(`\s*when:\s*(\d+)$`)
-)
+	// lineSplitter is implemented by parsers which history files are not
+	// newline delimited.
+	lineSplitter interface {
+		Split([]byte, bool) (int, []byte, error)
+	}
 
-type (
-	// Parser is a signature for a function which parses file and returns a Keeper.
-	Parser func(Keeper, *bufio.Scanner, *utils.Regexp, chan *HistoryEntry) (Keeper, error)
+	// formatDetector is implemented by parsers which detect a format of the
+	// history file by its first bytes. It is used if parsing starts from the
+	// middle of the file.
+	formatDetector interface {
+		DetectFormat([]byte)
+	}
 
-	// ShellSpecificParser is a signature for a function which implements shell specific logic for parsing.
-	ShellSpecificParser func(Keeper, string, uint, *HistoryEntry, *utils.Regexp, chan *HistoryEntry) (bool, uint, *HistoryEntry)
-)
+	// recordStartDetector is implemented by parsers which history may be
+	// read backwards. It tells if parsing may be started from the line
+	// which follows the previous one.
+	recordStartDetector interface {
+		IsRecordStart(previous string, line string) bool
+	}
 
-func getParser(env *environments.Environment) Parser {
-	var currentNumber uint
-	var shellSpecific ShellSpecificParser
-
-	switch env.Shell {
-	case environments.ShellBash:
-		shellSpecific = parseBash
-		currentNumber = 1
-	case environments.ShellZsh:
-		shellSpecific = parseZsh
-		currentNumber = 0
-	default:
-		utils.Logger.Panicf("Unknown shell %v", env.Shell)
+	// recordHolder is implemented by parsers which may keep a command until
+	// the next lines show that it is complete.
+	recordHolder interface {
+		HasPending() bool
 	}
+)
 
-	return func(keeper Keeper, scanner *bufio.Scanner, filter *utils.Regexp, historyChan chan *HistoryEntry) (Keeper, error) {
-		defer close(historyChan)
-
-		continueToConsume := false
-		currentEvent := keeper.Init()
-
-		for keeper.Continue() && scanner.Scan() {
-			text := scanner.Text()
-
-			utils.Logger.WithFields(logrus.Fields{
-				"text":              text,
-				"continueToConsume": continueToConsume,
-				"currentEvent":      currentEvent,
-			}).Info("Parse history line")
-
-			if continueToConsume {
-				utils.Logger.Info("Attach the line to the previous command")
-
-				currentEvent.command += "\n" + text
-				if strings.HasSuffix(text, `\`) {
-					continue
-				}
-				continueToConsume = false
-				utils.Logger.WithFields(logrus.Fields{
-					"event": currentEvent,
-				}).Info("Commit event")
-				currentEvent = keeper.Commit(currentEvent, historyChan)
-			}
-
-			if text == "" {
-				utils.Logger.Info("Skip empty line")
-				continue
-			}
-
-			continueToConsume, currentNumber, currentEvent = shellSpecific(
-				keeper,
-				text,
-				currentNumber,
-				currentEvent,
-				filter,
-				historyChan)
-		}
+// ParseContext carries a state of parsing shared between generic and shell
+// specific parsers.
+type ParseContext struct {
+	keeper       Keeper
+	filter       Filter
+	historyChan  chan *HistoryEntry
+	current      *HistoryEntry
+	number       uint
+	continued    bool
+	unterminated bool
+	commits      int
+	offset       int64
+	lineOffset   int64
+	checkpoint   parseCheckpoint
+}
 
-		if err := scanner.Err(); err != nil {
-			return nil, err
-		}
-		return keeper, nil
-	}
+// parseCheckpoint is the last position in the history file where parsing
+// may be started again with the fresh parser.
+type parseCheckpoint struct {
+	offset  int64
+	number  uint
+	commits int
 }
 
-func parseBash(keeper Keeper, text string, currentNumber uint, currentEvent *HistoryEntry, filter *utils.Regexp,
-	historyChan chan *HistoryEntry) (bool, uint, *HistoryEntry) {
-	continueToConsume := false
-	if bashTimestampRegexp.Match(text) {
-		if converted, err := strconv.ParseInt(text[1:], 10, 64); err == nil {
-			utils.Logger.WithFields(logrus.Fields{
-				"timestamp": converted,
-			}).Info("Parse timestamp")
-			currentEvent.timestamp = converted
-		} else {
-			utils.Logger.WithFields(logrus.Fields{
-				"text":  text,
-				"error": err,
-			}).Warn("Cannot parse timestamp")
-		}
-	} else {
-		if filter == nil || filter.Match(text) {
-			currentEvent.command = text
-			currentEvent.number = currentNumber
-
-			continueToConsume = strings.HasSuffix(text, "\\")
-			if !continueToConsume {
-				utils.Logger.WithFields(logrus.Fields{
-					"event": currentEvent,
-				}).Info("Commit event")
-				currentEvent = keeper.Commit(currentEvent, historyChan)
-			}
-		} else {
-			utils.Logger.Info("Skip text line because of the filter.")
-		}
-		currentNumber++
+// NextNumber returns a number of the next command and increments the counter.
+func (pc *ParseContext) NextNumber() (number uint) {
+	number = pc.number
+	pc.number++
+
+	return
+}
+
+// Match checks if command passes the filter.
+func (pc *ParseContext) Match(command string) bool {
+	if pc.filter == nil || pc.filter.MatchCommand(command) {
+		return true
 	}
+	utils.Logger.Info("Skip command because of the filter.")
 
-	return continueToConsume, currentNumber, currentEvent
+	return false
 }
 
-func parseZsh(keeper Keeper, text string, currentNumber uint, currentEvent *HistoryEntry, filter *utils.Regexp,
-	historyChan chan *HistoryEntry) (bool, uint, *HistoryEntry) {
-	continueToConsume := false
-	groups, err := zshLineRegexp.Groups(text)
+// Continue tells if keeper wants more commands.
+func (pc *ParseContext) Continue() bool {
+	return pc.keeper.Continue()
+}
 
-	if err != nil {
-		utils.Logger.WithFields(logrus.Fields{
-			"error": err,
-		}).Warn("Cannot parse current line, skip.")
-		return continueToConsume, currentNumber, currentEvent
+// Commit commits the current event into the keeper.
+func (pc *ParseContext) Commit() {
+	utils.Logger.WithFields(logrus.Fields{
+		"event": pc.current,
+	}).Info("Commit event")
+	pc.current = pc.keeper.Commit(pc.current, pc.historyChan)
+	pc.commits++
+}
+
+// markCheckpoint remembers the offset as a checkpoint if there is no
+// incomplete command.
+func (pc *ParseContext) markCheckpoint(offset int64) {
+	if !pc.continued && *pc.current == (HistoryEntry{}) {
+		pc.checkpoint = parseCheckpoint{offset: offset, number: pc.number, commits: pc.commits}
 	}
-	timestamp, command := groups[0], groups[1]
-	currentNumber++
+}
 
-	if filter != nil && !filter.Match(command) {
-		utils.Logger.Info("Skip text line because of the filter.")
-		return continueToConsume, currentNumber, currentEvent
+// attachLine attaches the line to the command continued from the previous
+// line. If the line ends with marker, next line continues the command too,
+// otherwise command is committed. Returns true if command is still continued.
+func (pc *ParseContext) attachLine(text string, marker string) bool {
+	utils.Logger.Info("Attach the line to the previous command")
+
+	pc.current.command += "\n" + text
+	if strings.HasSuffix(text, marker) {
+		return true
 	}
+	pc.continued = false
+	pc.Commit()
 
-	converted, _ := strconv.ParseInt(timestamp, 10, 64)
-	currentEvent.command = command
-	currentEvent.number = currentNumber
-	currentEvent.timestamp = converted
+	return false
+}
+
+// parseHistory parses history records from the reader with the shell
+// specific parser and commits the commands into the keeper. Numbering starts
+// from the given number. The last line of the file may be still written by
+// the shell if it is not terminated, so it is parsed but never checkpointed.
+// The context is returned so caller may check the last checkpoint of parsing.
+func parseHistory(shellSpecific ShellSpecificParser, keeper Keeper, reader io.Reader, filter Filter,
+	historyChan chan *HistoryEntry, number uint) (*ParseContext, error) {
+	defer close(historyChan)
+
+	context := &ParseContext{
+		keeper:      keeper,
+		filter:      filter,
+		historyChan: historyChan,
+		current:     keeper.Init(),
+		number:      number,
+	}
+	context.checkpoint.number = number
+
+	split := bufio.ScanLines
+	if splitter, ok := shellSpecific.(lineSplitter); ok {
+		split = splitter.Split
+	}
+	scanner := bufio.NewScanner(reader)
+	scanner.Split(func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		advance, token, err = split(data, false)
+		if advance == 0 && token == nil && err == nil && atEOF && len(data) > 0 {
+			advance, token, err = split(data, true)
+			context.unterminated = token != nil
+		}
+		if token != nil {
+			context.lineOffset = context.offset
+		}
+		context.offset += int64(advance)
+		return
+	})
+	decoder, hasDecoder := shellSpecific.(lineDecoder)
+	holder, isHolder := shellSpecific.(recordHolder)
+
+	for keeper.Continue() && scanner.Scan() {
+		text := scanner.Text()
+		if hasDecoder {
+			text = decoder.Decode(text)
+		}
 
-	continueToConsume = strings.HasSuffix(text, `\`)
-	if !continueToConsume {
 		utils.Logger.WithFields(logrus.Fields{
-			"event": currentEvent,
-		}).Info("Commit event")
-		currentEvent = keeper.Commit(currentEvent, historyChan)
+			"text":         text,
+			"continued":    context.continued,
+			"currentEvent": context.current,
+		}).Info("Parse history line")
+
+		// empty lines may be a part of the command, e.g heredoc in bash
+		// record, so they are skipped only between commands.
+		if text == "" && !context.continued && !(isHolder && holder.HasPending()) {
+			utils.Logger.Info("Skip empty line")
+			continue
+		}
+
+		shellSpecific.Parse(context, text)
+		if !context.unterminated && (!isHolder || !holder.HasPending()) {
+			context.markCheckpoint(context.offset)
+		}
 	}
 
-	return continueToConsume, currentNumber, currentEvent
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if keeper.Continue() {
+		shellSpecific.Finish(context)
+	}
+	return context, nil
 }
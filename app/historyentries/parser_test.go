@@ -0,0 +1,117 @@
+package historyentries
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/9seconds/ah/app/environments"
+	"github.com/9seconds/ah/app/utils"
+)
+
+func init() {
+	utils.DisableLogging()
+}
+
+// expectedEntry is a part of the history entry which parsers are
+// responsible for.
+type expectedEntry struct {
+	number    uint
+	command   string
+	timestamp int64
+}
+
+// parseString parses the history with the parser of the shell set in the
+// environment and returns all committed entries.
+func parseString(t *testing.T, env *environments.Environment, history string) []HistoryEntry {
+	shell, err := getShell(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keeper := new(collectKeeper)
+	_, err = parseHistory(shell.NewParser(env), keeper, strings.NewReader(history), nil,
+		make(chan *HistoryEntry), shell.NumberBase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return keeper.entries
+}
+
+func checkEntries(t *testing.T, name string, entries []HistoryEntry, expected []expectedEntry) {
+	if len(entries) != len(expected) {
+		t.Errorf("%s: got %d entries %v, expected %d", name, len(entries), entries, len(expected))
+		return
+	}
+
+	for idx, entry := range entries {
+		actual := expectedEntry{number: entry.number, command: entry.command, timestamp: entry.timestamp}
+		if actual != expected[idx] {
+			t.Errorf("%s: entry %d is %+v, expected %+v", name, idx, actual, expected[idx])
+		}
+	}
+}
+
+func TestParseHistoryCheckpoint(t *testing.T) {
+	cases := []struct {
+		name       string
+		env        *environments.Environment
+		history    string
+		expected   []expectedEntry
+		checkpoint parseCheckpoint
+	}{
+		{
+			name:       "terminated",
+			env:        &environments.Environment{Shell: environments.ShellBash},
+			history:    "ls\npwd\n",
+			expected:   []expectedEntry{{1, "ls", 0}, {2, "pwd", 0}},
+			checkpoint: parseCheckpoint{offset: 7, number: 3, commits: 2},
+		},
+		{
+			name:       "unterminated",
+			env:        &environments.Environment{Shell: environments.ShellBash},
+			history:    "ls\npwd",
+			expected:   []expectedEntry{{1, "ls", 0}, {2, "pwd", 0}},
+			checkpoint: parseCheckpoint{offset: 3, number: 2, commits: 1},
+		},
+		{
+			name:       "continued",
+			env:        &environments.Environment{Shell: environments.ShellZsh},
+			history:    ": 1430000000:0;ls\n: 1430000001:0;echo \\\n",
+			expected:   []expectedEntry{{1, "ls", 1430000000}},
+			checkpoint: parseCheckpoint{offset: 18, number: 2, commits: 1},
+		},
+		{
+			name: "unterminated record",
+			env: &environments.Environment{
+				Shell:       environments.ShellBash,
+				BashRecords: environments.BashRecordsTimestamps,
+			},
+			history:    "#1430000000\nls\n#1430000001\npwd",
+			expected:   []expectedEntry{{1, "ls", 1430000000}, {2, "pwd", 1430000001}},
+			checkpoint: parseCheckpoint{offset: 15, number: 2, commits: 1},
+		},
+		{
+			name:       "unterminated binary",
+			env:        &environments.Environment{Shell: environments.ShellMksh},
+			history:    "\xab\xcd\xff\x00\x00\x00\x01ls\x00\xff\x00\x00\x00\x02pwd",
+			expected:   []expectedEntry{{1, "ls", 0}, {2, "pwd", 0}},
+			checkpoint: parseCheckpoint{offset: 10, number: 2, commits: 1},
+		},
+	}
+
+	for _, testCase := range cases {
+		shell, _ := getShell(testCase.env)
+		keeper := new(collectKeeper)
+		context, err := parseHistory(shell.NewParser(testCase.env), keeper, strings.NewReader(testCase.history), nil,
+			make(chan *HistoryEntry), shell.NumberBase)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkEntries(t, testCase.name, keeper.entries, testCase.expected)
+		if context.checkpoint != testCase.checkpoint {
+			t.Errorf("%s: checkpoint is %+v, expected %+v", testCase.name, context.checkpoint, testCase.checkpoint)
+		}
+	}
+}
@@ -0,0 +1,480 @@
+package historyentries
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/9seconds/ah/app/environments"
+	"github.com/9seconds/ah/app/utils"
+)
+
+type queryTokenKind uint8
+
+const (
+	queryTokenWord queryTokenKind = iota
+	queryTokenQuoted
+	queryTokenRegexp
+	queryTokenOpen
+	queryTokenClose
+)
+
+var (
+	queryFields = map[string]bool{
+		"cmd":    true,
+		"traced": true,
+		"size":   true,
+		"since":  true,
+		"until":  true,
+	}
+
+	querySizeUnits = map[string]int64{
+		"":    1,
+		"b":   1,
+		"k":   1024,
+		"kb":  1024,
+		"kib": 1024,
+		"m":   1024 * 1024,
+		"mb":  1024 * 1024,
+		"mib": 1024 * 1024,
+		"g":   1024 * 1024 * 1024,
+		"gb":  1024 * 1024 * 1024,
+		"gib": 1024 * 1024 * 1024,
+	}
+
+	querySizeRegexp = utils.CreateRegexp(`^(>=|<=|>|<|=)?(\d+(?:\.\d+)?)\s*([a-zA-Z]*)$`)
+)
+
+type queryToken struct {
+	kind  queryTokenKind
+	field string
+	value string
+}
+
+// queryNode is a node of the compiled query. If commandOnly is set, only the
+// command of the entry is known and result is not known if it depends on
+// the rest of the entry.
+type queryNode interface {
+	match(entry *HistoryEntry, commandOnly bool) (matched bool, known bool)
+}
+
+type (
+	queryAnd struct {
+		left  queryNode
+		right queryNode
+	}
+
+	queryOr struct {
+		left  queryNode
+		right queryNode
+	}
+
+	queryNot struct {
+		node queryNode
+	}
+
+	queryCommand struct {
+		regexp *utils.Regexp
+	}
+
+	queryCondition struct {
+		condition Condition
+	}
+)
+
+// queryFilter is a filter compiled from the query.
+type queryFilter struct {
+	query string
+	root  queryNode
+}
+
+// queryParser is a recursive descent parser of the query language.
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+	traces *traceSizes
+}
+
+// traceSizes lazily loads sizes of the traces for the trace predicates.
+type traceSizes struct {
+	env   *environments.Environment
+	once  sync.Once
+	sizes map[string]int64
+}
+
+// CompileQuery compiles the query into the filter. Query is a list of
+// terms joined with AND, OR and NOT operators and parentheses, terms
+// without an operator are joined with AND. A term is a text which command
+// has to contain, "quoted text" or /regular expression/. Terms with field
+// prefix match other attributes of the entry:
+//
+//	cmd:TERM           command matches the term
+//	traced:yes|no      command has an output stored or not
+//	size:[<|>|=]SIZE   size of the stored output, e.g. size:>10K
+//	since:TIME         command is executed at TIME or later, e.g. since:3d
+//	until:TIME         command is executed before TIME
+func CompileQuery(query string, env *environments.Environment) (Filter, error) {
+	tokens, err := tokenizeQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("Query is empty")
+	}
+
+	parser := &queryParser{tokens: tokens, traces: &traceSizes{env: env}}
+	root, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if parser.pos < len(parser.tokens) {
+		return nil, fmt.Errorf("Cannot parse query: unexpected %s", parser.tokens[parser.pos])
+	}
+
+	return &queryFilter{query: query, root: root}, nil
+}
+
+func (qf *queryFilter) Match(entry *HistoryEntry) bool {
+	matched, _ := qf.root.match(entry, false)
+	return matched
+}
+
+func (qf *queryFilter) MatchCommand(command string) bool {
+	matched, known := qf.root.match(&HistoryEntry{command: command}, true)
+	return matched || !known
+}
+
+func (qf *queryFilter) String() string {
+	return qf.query
+}
+
+func (qt queryToken) String() string {
+	switch qt.kind {
+	case queryTokenOpen:
+		return "("
+	case queryTokenClose:
+		return ")"
+	case queryTokenQuoted:
+		return strconv.Quote(qt.value)
+	case queryTokenRegexp:
+		return "/" + qt.value + "/"
+	}
+	if qt.field != "" {
+		return qt.field + ":" + qt.value
+	}
+	return qt.value
+}
+
+func (qt queryToken) isOperator(operator string) bool {
+	return qt.kind == queryTokenWord && qt.field == "" && qt.value == operator
+}
+
+// tokenizeQuery splits the query into tokens.
+func tokenizeQuery(query string) (tokens []queryToken, err error) {
+	runes := []rune(query)
+
+	for pos := 0; pos < len(runes); {
+		switch character := runes[pos]; {
+		case character == ' ' || character == '\t' || character == '\n':
+			pos++
+		case character == '(':
+			tokens = append(tokens, queryToken{kind: queryTokenOpen})
+			pos++
+		case character == ')':
+			tokens = append(tokens, queryToken{kind: queryTokenClose})
+			pos++
+		default:
+			var token queryToken
+			if token, pos, err = readQueryTerm(runes, pos); err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token)
+		}
+	}
+
+	return
+}
+
+// readQueryTerm reads the term which starts from the given position and
+// returns it with the position after the term.
+func readQueryTerm(runes []rune, pos int) (queryToken, int, error) {
+	token := queryToken{kind: queryTokenWord}
+
+	start := pos
+	for pos < len(runes) && !strings.ContainsRune(" \t\n():\"/", runes[pos]) {
+		pos++
+	}
+	if pos < len(runes) && runes[pos] == ':' && queryFields[string(runes[start:pos])] {
+		token.field = string(runes[start:pos])
+		pos++
+		start = pos
+	} else {
+		pos = start
+	}
+
+	if pos < len(runes) && (runes[pos] == '"' || runes[pos] == '/') {
+		delimiter := runes[pos]
+		value := make([]rune, 0, len(runes)-pos)
+		for pos++; pos < len(runes) && runes[pos] != delimiter; pos++ {
+			if runes[pos] == '\\' && pos+1 < len(runes) && runes[pos+1] == delimiter {
+				pos++
+			}
+			value = append(value, runes[pos])
+		}
+		if pos == len(runes) {
+			return token, pos, fmt.Errorf("Cannot parse query: %c is not closed", delimiter)
+		}
+
+		token.kind = queryTokenQuoted
+		if delimiter == '/' {
+			token.kind = queryTokenRegexp
+		}
+		token.value = string(value)
+		return token, pos + 1, nil
+	}
+
+	for pos < len(runes) && !strings.ContainsRune(" \t\n()", runes[pos]) {
+		pos++
+	}
+	token.value = string(runes[start:pos])
+	if token.value == "" {
+		return token, pos, fmt.Errorf("Cannot parse query: %s has no value", token.field)
+	}
+
+	return token, pos, nil
+}
+
+func (qp *queryParser) peek() *queryToken {
+	if qp.pos < len(qp.tokens) {
+		return &qp.tokens[qp.pos]
+	}
+	return nil
+}
+
+func (qp *queryParser) parseOr() (queryNode, error) {
+	node, err := qp.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for token := qp.peek(); token != nil && token.isOperator("OR"); token = qp.peek() {
+		qp.pos++
+		right, err := qp.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		node = &queryOr{left: node, right: right}
+	}
+
+	return node, nil
+}
+
+func (qp *queryParser) parseAnd() (queryNode, error) {
+	node, err := qp.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for token := qp.peek(); token != nil && token.kind != queryTokenClose && !token.isOperator("OR"); token = qp.peek() {
+		if token.isOperator("AND") {
+			qp.pos++
+		}
+		right, err := qp.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		node = &queryAnd{left: node, right: right}
+	}
+
+	return node, nil
+}
+
+func (qp *queryParser) parseNot() (queryNode, error) {
+	token := qp.peek()
+	if token != nil && token.isOperator("NOT") {
+		qp.pos++
+		node, err := qp.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &queryNot{node: node}, nil
+	}
+
+	return qp.parsePrimary()
+}
+
+func (qp *queryParser) parsePrimary() (queryNode, error) {
+	token := qp.peek()
+	switch {
+	case token == nil:
+		return nil, fmt.Errorf("Cannot parse query: unexpected end of query")
+	case token.kind == queryTokenClose || token.isOperator("AND") || token.isOperator("OR"):
+		return nil, fmt.Errorf("Cannot parse query: unexpected %s", token)
+	}
+	qp.pos++
+
+	if token.kind != queryTokenOpen {
+		return qp.compileTerm(token)
+	}
+
+	node, err := qp.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if token = qp.peek(); token == nil || token.kind != queryTokenClose {
+		return nil, fmt.Errorf("Cannot parse query: ( is not closed")
+	}
+	qp.pos++
+
+	return node, nil
+}
+
+// compileTerm converts the term token into the query node.
+func (qp *queryParser) compileTerm(token *queryToken) (queryNode, error) {
+	if token.field == "" || token.field == "cmd" {
+		expression := token.value
+		if token.kind != queryTokenRegexp {
+			expression = regexp.QuoteMeta(expression)
+		}
+		compiled, err := utils.CompileRegexp(expression)
+		if err != nil {
+			return nil, err
+		}
+		return &queryCommand{regexp: compiled}, nil
+	}
+
+	var condition Condition
+	switch token.field {
+	case "traced":
+		traced, err := parseQueryBool(token.value)
+		if err != nil {
+			return nil, err
+		}
+		condition = func(entry *HistoryEntry) bool {
+			_, found := qp.traces.get(entry)
+			return found == traced
+		}
+	case "size":
+		compare, err := parseQuerySize(token.value)
+		if err != nil {
+			return nil, err
+		}
+		condition = func(entry *HistoryEntry) bool {
+			size, found := qp.traces.get(entry)
+			return found && compare(size)
+		}
+	case "since", "until":
+		parsed, err := utils.ParseTime(token.value, time.Unix(environments.CreatedAt, 0))
+		if err != nil {
+			return nil, err
+		}
+		if token.field == "since" {
+			condition = Since(parsed)
+		} else {
+			condition = Until(parsed)
+		}
+	}
+
+	return &queryCondition{condition: condition}, nil
+}
+
+func parseQueryBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "yes", "y", "true", "1":
+		return true, nil
+	case "no", "n", "false", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("Cannot parse query: %s is not yes or no", value)
+}
+
+// parseQuerySize parses the size with optional comparison operator and
+// returns the comparison function. Size without operator means "at least".
+func parseQuerySize(value string) (func(int64) bool, error) {
+	groups, err := querySizeRegexp.Groups(value)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot parse query: %s is not a size", value)
+	}
+	multiplier, ok := querySizeUnits[strings.ToLower(groups[2])]
+	if !ok {
+		return nil, fmt.Errorf("Cannot parse query: unknown size unit %s", groups[2])
+	}
+	number, _ := strconv.ParseFloat(groups[1], 64)
+	size := int64(number * float64(multiplier))
+
+	switch groups[0] {
+	case ">":
+		return func(traceSize int64) bool { return traceSize > size }, nil
+	case "<":
+		return func(traceSize int64) bool { return traceSize < size }, nil
+	case "<=":
+		return func(traceSize int64) bool { return traceSize <= size }, nil
+	case "=":
+		return func(traceSize int64) bool { return traceSize == size }, nil
+	}
+	return func(traceSize int64) bool { return traceSize >= size }, nil
+}
+
+func (qa *queryAnd) match(entry *HistoryEntry, commandOnly bool) (bool, bool) {
+	left, leftKnown := qa.left.match(entry, commandOnly)
+	if leftKnown && !left {
+		return false, true
+	}
+	right, rightKnown := qa.right.match(entry, commandOnly)
+	if rightKnown && !right {
+		return false, true
+	}
+	return true, leftKnown && rightKnown
+}
+
+func (qo *queryOr) match(entry *HistoryEntry, commandOnly bool) (bool, bool) {
+	left, leftKnown := qo.left.match(entry, commandOnly)
+	if leftKnown && left {
+		return true, true
+	}
+	right, rightKnown := qo.right.match(entry, commandOnly)
+	if rightKnown && right {
+		return true, true
+	}
+	return false, leftKnown && rightKnown
+}
+
+func (qn *queryNot) match(entry *HistoryEntry, commandOnly bool) (bool, bool) {
+	matched, known := qn.node.match(entry, commandOnly)
+	return !matched, known
+}
+
+func (qc *queryCommand) match(entry *HistoryEntry, commandOnly bool) (bool, bool) {
+	return qc.regexp.Match(entry.command), true
+}
+
+func (qc *queryCondition) match(entry *HistoryEntry, commandOnly bool) (bool, bool) {
+	if commandOnly {
+		return false, false
+	}
+	return qc.condition(entry), true
+}
+
+// get returns the size of the trace of the entry and if it exists at all.
+func (ts *traceSizes) get(entry *HistoryEntry) (int64, bool) {
+	if entry.hasHistory {
+		return entry.traceSize, true
+	}
+
+	ts.once.Do(func() {
+		ts.sizes = make(map[string]int64)
+		files, err := ts.env.GetTracesFileInfos()
+		if err != nil {
+			utils.Logger.WithField("error", err).Warn("Error on traces directory listing")
+		}
+		for _, file := range files {
+			ts.sizes[file.Name()] = file.Size()
+		}
+	})
+
+	size, found := ts.sizes[entry.GetTraceName()]
+	return size, found
+}
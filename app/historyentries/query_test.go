@@ -0,0 +1,125 @@
+package historyentries
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/9seconds/ah/app/environments"
+)
+
+func TestCompileQuery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ah")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	env := &environments.Environment{TracesDir: dir}
+	now := environments.CreatedAt
+	entries := []HistoryEntry{
+		{number: 1, command: "git status", timestamp: now - 10*24*60*60},
+		{number: 2, command: "git push origin master", timestamp: now - 2*60*60, hasHistory: true, traceSize: 20 * 1024},
+		{number: 3, command: "make test", timestamp: now - 60, hasHistory: true, traceSize: 100},
+		{number: 4, command: `echo "quoted (text)"`, timestamp: now - 30},
+		{number: 5, command: "Make install", timestamp: now - 10},
+	}
+	cases := []struct {
+		query    string
+		expected []uint
+	}{
+		{"git", []uint{1, 2}},
+		{"git push", []uint{2}},
+		{"git AND push", []uint{2}},
+		{"push OR make", []uint{2, 3}},
+		{"git NOT push", []uint{1}},
+		{"NOT git", []uint{3, 4, 5}},
+		{"NOT NOT git", []uint{1, 2}},
+		{"(push OR status) git", []uint{1, 2}},
+		{"make OR git push", []uint{2, 3}},
+		{`"quoted (text)"`, []uint{4}},
+		{`"git push"`, []uint{2}},
+		{`/^[mM]ake/`, []uint{3, 5}},
+		{`cmd:/status$/`, []uint{1}},
+		{"traced:yes", []uint{2, 3}},
+		{"traced:no", []uint{1, 4, 5}},
+		{"size:>1K", []uint{2}},
+		{"size:<=100", []uint{3}},
+		{"size:=20KiB", []uint{2}},
+		{"size:0", []uint{2, 3}},
+		{"since:1d", []uint{2, 3, 4, 5}},
+		{"until:1h", []uint{1, 2}},
+		{"since:1h OR traced:yes", []uint{2, 3, 4, 5}},
+		{"make NOT traced:yes", []uint{}},
+		{"echo:", []uint{}},
+	}
+
+	for _, testCase := range cases {
+		filter, err := CompileQuery(testCase.query, env)
+		if err != nil {
+			t.Errorf("%s: %v", testCase.query, err)
+			continue
+		}
+
+		matched := []uint{}
+		for idx := range entries {
+			if filter.Match(&entries[idx]) {
+				matched = append(matched, entries[idx].number)
+			}
+		}
+		if !reflect.DeepEqual(matched, testCase.expected) {
+			t.Errorf("%s: matched %v, expected %v", testCase.query, matched, testCase.expected)
+		}
+	}
+}
+
+func TestCompileQueryErrors(t *testing.T) {
+	queries := []string{
+		"",
+		"  ",
+		"git AND",
+		"OR git",
+		"(git",
+		"git)",
+		`"git`,
+		"/git",
+		"/(/",
+		"traced:maybe",
+		"size:big",
+		"size:10X",
+		"since:never",
+		"size:",
+	}
+
+	for _, query := range queries {
+		if _, err := CompileQuery(query, new(environments.Environment)); err == nil {
+			t.Errorf("%q is compiled without errors", query)
+		}
+	}
+}
+
+func TestQueryMatchCommand(t *testing.T) {
+	cases := []struct {
+		query    string
+		command  string
+		expected bool
+	}{
+		{"git", "git status", true},
+		{"git", "make", false},
+		{"git traced:yes", "git status", true},
+		{"git traced:yes", "make", false},
+		{"git OR traced:yes", "make", true},
+		{"NOT traced:yes", "make", true},
+	}
+
+	for _, testCase := range cases {
+		filter, err := CompileQuery(testCase.query, new(environments.Environment))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := filter.MatchCommand(testCase.command); actual != testCase.expected {
+			t.Errorf("%s: MatchCommand(%q) is %t, expected %t", testCase.query, testCase.command, actual, testCase.expected)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package historyentries
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	logrus "github.com/Sirupsen/logrus"
+
+	"github.com/9seconds/ah/app/environments"
+	"github.com/9seconds/ah/app/utils"
+)
+
+// EntryRecord is a metadata of the command which history file does not
+// store. It is recorded by shell hooks after the command is finished and
+// it is bound to the history entry by its trace name.
+type EntryRecord struct {
+	Trace      string `json:"trace"`
+	Directory  string `json:"cwd"`
+	ExitStatus int    `json:"exit"`
+	Started    int64  `json:"started,omitempty"`
+	Finished   int64  `json:"finished"`
+	Host       string `json:"host"`
+	TTY        string `json:"tty,omitempty"`
+}
+
+// Records maps trace names of the history entries to their records.
+type Records map[string]*EntryRecord
+
+// HasDuration tells if record knows when the command was started.
+func (er *EntryRecord) HasDuration() bool {
+	return er.Started > 0 && er.Finished >= er.Started
+}
+
+// GetDuration returns how long the command was executed.
+func (er *EntryRecord) GetDuration() int64 {
+	if !er.HasDuration() {
+		return 0
+	}
+	return er.Finished - er.Started
+}
+
+// GetShortDirectory returns the directory with home directory replaced by ~.
+func (er *EntryRecord) GetShortDirectory(env *environments.Environment) string {
+	if env.HomeDir == "" {
+		return er.Directory
+	}
+	if er.Directory == env.HomeDir {
+		return "~"
+	}
+	if strings.HasPrefix(er.Directory, env.HomeDir+string(filepath.Separator)) {
+		return "~" + er.Directory[len(env.HomeDir):]
+	}
+	return er.Directory
+}
+
+// AppendRecord appends the record to the records file. Records file is
+// append only so shell hooks may write it cheaply, the latest record of the
+// entry wins.
+func AppendRecord(record *EntryRecord, env *environments.Environment) error {
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(env.RecordsFileName, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// the record is written with the single call so concurrent hooks of
+	// different shells do not mix their lines.
+	_, err = file.Write(append(encoded, '\n'))
+	return err
+}
+
+// LoadRecords reads all records of the environment. Absent records file
+// means there are no records.
+func LoadRecords(env *environments.Environment) (Records, error) {
+	records := make(Records)
+
+	file, err := os.Open(env.RecordsFileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return records, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		record := new(EntryRecord)
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			utils.Logger.WithFields(logrus.Fields{
+				"line":  scanner.Text(),
+				"error": err,
+			}).Warn("Cannot parse a record so skip")
+			continue
+		}
+		records[record.Trace] = record
+	}
+
+	return records, scanner.Err()
+}
@@ -0,0 +1,198 @@
+package historyentries
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	logrus "github.com/Sirupsen/logrus"
+
+	"github.com/9seconds/ah/app/environments"
+	"github.com/9seconds/ah/app/utils"
+)
+
+const (
+	// reverseBlockSize is the size of the block reverse reader starts with.
+	// Block is doubled until it has a record boundary.
+	reverseBlockSize = 32 * 1024
+
+	// reverseTailLimit is the size of not indexed tail reverse reader may
+	// parse without updating the index.
+	reverseTailLimit = 1024 * 1024
+)
+
+// reverseReader reads history entries from the end of the history file.
+// Numbers of the entries are taken from the index header: it knows the
+// number of the first command after the indexed part of the file so the
+// file is read in blocks backwards from that offset. Each block starts on a
+// record boundary detected by the parser and is parsed forward as usual.
+type reverseReader struct {
+	history  *historyFile
+	detector recordStartDetector
+	offset   int64
+	number   uint
+	entries  []HistoryEntry
+}
+
+func getReverseReader(env *environments.Environment) (*reverseReader, error) {
+	history, err := openHistoryFile(env)
+	if err != nil {
+		return nil, err
+	}
+	reader := &reverseReader{history: history}
+	// boundaries are searched only in the middle of the file.
+	reader.detector, _ = history.newParser(reverseBlockSize).(recordStartDetector)
+
+	header := history.loadIndexHeader()
+	if !history.isValidIndex(header) || history.size-header.Offset > reverseTailLimit {
+		utils.Logger.Info("Update the index before reading history backwards")
+		reader.entries, err = history.updateIndex()
+	} else {
+		reader.entries, _, err = history.parseTail(header)
+		reader.offset = header.Offset
+		reader.number = header.NextNumber
+	}
+	if err != nil {
+		history.Close()
+		return nil, err
+	}
+
+	return reader, nil
+}
+
+// getLastEntries returns entries from the end of the history file in
+// chronological order. Entries are read backwards while enough returns false
+// for the entries collected so far. Only entries which pass the filter and
+// the conditions are collected.
+func getLastEntries(env *environments.Environment, filter Filter, conditions []Condition,
+	enough func([]HistoryEntry, *HistoryEntry) bool) ([]HistoryEntry, error) {
+	reader, err := getReverseReader(env)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var entries []HistoryEntry
+	for {
+		entry, err := reader.Previous()
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil || enough(entries, entry) {
+			break
+		}
+		if matchFilter(entry, filter) && matchConditions(entry, conditions) {
+			entries = append(entries, *entry)
+		}
+	}
+
+	for left, right := 0, len(entries)-1; left < right; left, right = left+1, right-1 {
+		entries[left], entries[right] = entries[right], entries[left]
+	}
+
+	return entries, nil
+}
+
+func (rr *reverseReader) Close() error {
+	return rr.history.Close()
+}
+
+// Previous returns the entry before the last returned one or nil if the
+// beginning of the file is reached.
+func (rr *reverseReader) Previous() (*HistoryEntry, error) {
+	for len(rr.entries) == 0 {
+		if rr.offset == 0 {
+			return nil, nil
+		}
+		if err := rr.readBlock(); err != nil {
+			return nil, err
+		}
+	}
+
+	entry := &rr.entries[len(rr.entries)-1]
+	rr.entries = rr.entries[:len(rr.entries)-1]
+
+	return entry, nil
+}
+
+func (rr *reverseReader) readBlock() error {
+	for size := int64(reverseBlockSize); ; size *= 2 {
+		start := rr.offset - size
+		if start < 0 {
+			start = 0
+		}
+
+		data := make([]byte, rr.offset-start)
+		if _, err := rr.history.file.ReadAt(data, start); err != nil && err != io.EOF {
+			return err
+		}
+
+		if start == 0 {
+			return rr.parseBlock(0, data)
+		}
+		if boundary := rr.findBoundary(data); boundary >= 0 {
+			return rr.parseBlock(start+int64(boundary), data[boundary:])
+		}
+	}
+}
+
+// findBoundary returns a position of the first line in the data which
+// starts a record or -1 if there is no such line. The first line of the data
+// may be incomplete so it is used only as a previous one.
+func (rr *reverseReader) findBoundary(data []byte) int {
+	if rr.detector == nil {
+		return -1
+	}
+
+	previousStart := bytes.IndexByte(data, '\n') + 1
+	for previousStart > 0 {
+		previousLength := bytes.IndexByte(data[previousStart:], '\n')
+		lineStart := previousStart + previousLength + 1
+		if previousLength < 0 || lineStart == len(data) {
+			return -1
+		}
+		lineLength := bytes.IndexByte(data[lineStart:], '\n')
+		if lineLength < 0 {
+			lineLength = len(data) - lineStart
+		}
+
+		previous := string(data[previousStart : lineStart-1])
+		if rr.detector.IsRecordStart(previous, string(data[lineStart:lineStart+lineLength])) {
+			return lineStart
+		}
+		previousStart = lineStart
+	}
+
+	return -1
+}
+
+// parseBlock parses the block which ends on the current offset and
+// numbers its entries backwards from the current number.
+func (rr *reverseReader) parseBlock(offset int64, data []byte) error {
+	keeper := new(collectKeeper)
+	context, err := parseHistory(rr.history.newParser(offset), keeper, bytes.NewReader(data), nil,
+		make(chan *HistoryEntry), 0)
+	if err != nil {
+		return err
+	}
+
+	if context.number > rr.number {
+		return errors.New("History file was changed during reading")
+	}
+	first := rr.number - context.number
+	for idx := range keeper.entries {
+		keeper.entries[idx].number += first
+	}
+
+	utils.Logger.WithFields(logrus.Fields{
+		"offset":  offset,
+		"block":   len(data),
+		"entries": len(keeper.entries),
+	}).Info("Parse block of the history file backwards")
+
+	rr.entries = keeper.entries
+	rr.offset = offset
+	rr.number = first
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package historyentries
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/9seconds/ah/app/environments"
+)
+
+func TestReverseReader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ah")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		name    string
+		env     *environments.Environment
+		records func(int) string
+	}{
+		{
+			name: "bash",
+			env:  &environments.Environment{Shell: environments.ShellBash},
+			records: func(idx int) string {
+				if idx%10 == 0 {
+					return fmt.Sprintf("echo %d \\\ncontinued\n", idx)
+				}
+				return fmt.Sprintf("#%d\necho %d\n", 1430000000+idx, idx)
+			},
+		},
+		{
+			name: "zsh",
+			env:  &environments.Environment{Shell: environments.ShellZsh},
+			records: func(idx int) string {
+				return fmt.Sprintf(": %d:0;echo %d \\\n%s\n", 1430000000+idx, idx, strings.Repeat("x", idx%50))
+			},
+		},
+		{
+			name: "tcsh",
+			env:  &environments.Environment{Shell: environments.ShellTcsh},
+			records: func(idx int) string {
+				return fmt.Sprintf("#+%d\nforeach a (1 2)\necho %d\nend\n", 1430000000+idx, idx)
+			},
+		},
+	}
+
+	for _, testCase := range cases {
+		env := testCase.env
+		env.HistFile = filepath.Join(dir, testCase.name+"_history")
+		env.IndexDir = dir
+
+		history := make([]string, 5000)
+		for idx := range history {
+			history[idx] = testCase.records(idx)
+		}
+		indexed := strings.Join(history[:len(history)-10], "")
+		if err = ioutil.WriteFile(env.HistFile, []byte(indexed), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = getIndexedEntries(env); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(env.HistFile, []byte(strings.Join(history, "")), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := parseString(t, env, strings.Join(history, ""))
+
+		for _, count := range []int{1, 15, 3000, len(expected) + 1} {
+			entries, err := getLastEntries(env, nil, nil, func(entries []HistoryEntry, _ *HistoryEntry) bool {
+				return len(entries) >= count
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start := len(expected) - count
+			if start < 0 {
+				start = 0
+			}
+			expectedEntries := make([]expectedEntry, 0, len(expected)-start)
+			for _, entry := range expected[start:] {
+				expectedEntries = append(expectedEntries, expectedEntry{entry.number, entry.command, entry.timestamp})
+			}
+			checkEntries(t, fmt.Sprintf("%s last %d", testCase.name, count), entries, expectedEntries)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package historyentries
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/9seconds/ah/app/environments"
+	"github.com/9seconds/ah/app/fuzzy"
+)
+
+// recencyBonus is the maximal bonus the most recent command gets to its
+// fuzzy score. Older commands get proportionally less.
+const recencyBonus = 12
+
+const (
+	highlightStart = "\x1b[1;31m"
+	highlightEnd   = "\x1b[0m"
+)
+
+// ScoredEntry is a history entry matched by fuzzy search.
+type ScoredEntry struct {
+	UniqueEntry
+	score     int
+	positions []int
+	collapsed bool
+	highlight bool
+}
+
+type scoredEntries []ScoredEntry
+
+func (se scoredEntries) Len() int {
+	return len(se)
+}
+
+func (se scoredEntries) Less(i, j int) bool {
+	return se[i].score < se[j].score
+}
+
+func (se scoredEntries) Swap(i, j int) {
+	se[i], se[j] = se[j], se[i]
+}
+
+// ScoreEntries ranks the entries by the fuzzy score of the patterns. Score
+// of the entry is a sum of the scores of all patterns. Entries are sorted by
+// score, the best match goes last so the most relevant entries are close to
+// the prompt. Entries which do not match are dropped. If highlight is set,
+// matched characters are highlighted in the text output.
+func ScoreEntries(entries []HistoryEntry, patterns []string, highlight bool) []ScoredEntry {
+	unique := make([]UniqueEntry, len(entries))
+	for idx, entry := range entries {
+		unique[idx] = UniqueEntry{HistoryEntry: entry, count: 1, firstTimestamp: entry.timestamp}
+	}
+
+	return scoreEntries(unique, patterns, false, highlight)
+}
+
+// ScoreUniqueEntries ranks the collapsed entries as ScoreEntries does.
+func ScoreUniqueEntries(entries []UniqueEntry, patterns []string, highlight bool) []ScoredEntry {
+	return scoreEntries(entries, patterns, true, highlight)
+}
+
+func scoreEntries(entries []UniqueEntry, patterns []string, collapsed bool, highlight bool) []ScoredEntry {
+	scored := make([]ScoredEntry, 0, len(entries))
+	for idx, entry := range entries {
+		if current, ok := scoreEntry(entry, patterns); ok {
+			current.score += recencyBonus * (idx + 1) / len(entries)
+			current.collapsed = collapsed
+			current.highlight = highlight
+			scored = append(scored, current)
+		}
+	}
+	sort.Stable(scoredEntries(scored))
+
+	return scored
+}
+
+func scoreEntry(entry UniqueEntry, patterns []string) (scored ScoredEntry, ok bool) {
+	scored.UniqueEntry = entry
+	for _, pattern := range patterns {
+		var match *fuzzy.Match
+		if match, ok = fuzzy.Score(pattern, entry.command); !ok {
+			return
+		}
+		scored.score += match.Score
+		scored.positions = append(scored.positions, match.Positions...)
+	}
+	sort.Ints(scored.positions)
+
+	return
+}
+
+// GetScore returns the fuzzy score of the entry, higher is better.
+func (se ScoredEntry) GetScore() int {
+	return se.score
+}
+
+// GetPositions returns positions of the matched characters in the command.
+func (se ScoredEntry) GetPositions() []int {
+	return se.positions
+}
+
+// ToString converts scored entry to the string representation according to the environment setting.
+func (se ScoredEntry) ToString(env *environments.Environment, showDuration bool) string {
+	text := highlightCommand(se.command, se.positions, se.highlight)
+	if se.collapsed {
+		return se.formatLine(env, showDuration, fmt.Sprintf(" %4dx", se.count), se.getFormattedTimes(env), text)
+	}
+	return se.formatLine(env, showDuration, "", env.FormatTimeStamp(se.timestamp), se.getPrompt(env)+text)
+}
+
+// highlightCommand highlights characters of the command matched by fuzzy
+// search if highlighting is enabled.
+func highlightCommand(command string, positions []int, highlight bool) string {
+	if !highlight {
+		return command
+	}
+	return fuzzy.Highlight(command, positions, highlightStart, highlightEnd)
+}
@@ -0,0 +1,155 @@
+package historyentries
+
+import (
+	"strconv"
+	"strings"
+
+	logrus "github.com/Sirupsen/logrus"
+
+	"github.com/9seconds/ah/app/environments"
+	"github.com/9seconds/ah/app/utils"
+)
+
+var bashTimestampRegexp = utils.CreateRegexp(`^#\s*(\d+)$`)
+
+type bashLinesParser struct{}
+
+// recordsParser parses history where commands are delimited by timestamp
+// lines so all lines between 2 timestamps belong to the same command. Until
+// the first timestamp line is met, history is parsed by fallback parser or
+// line by line if there is no fallback.
+type recordsParser struct {
+	timestampRegexp *utils.Regexp
+	fallback        ShellSpecificParser
+	seenTimestamp   bool
+	pending         bool
+	pendingNumber   uint
+}
+
+func init() {
+	RegisterShell(&Shell{
+		Name:       environments.ShellBash,
+		HistFile:   ".bash_history",
+		NumberBase: 1,
+		Detect:     detectByNames([]string{"bash", "rbash"}, []string{".bash_history"}),
+		NewParser: func(env *environments.Environment) ShellSpecificParser {
+			// With "shopt -s cmdhist lithist" bash writes multiline commands
+			// (loops, heredocs) as is.
+			if env.BashRecords == environments.BashRecordsTimestamps {
+				return &recordsParser{
+					timestampRegexp: bashTimestampRegexp,
+					fallback:        new(bashLinesParser),
+				}
+			}
+			return new(bashLinesParser)
+		},
+	})
+}
+
+func (bp *bashLinesParser) Parse(context *ParseContext, text string) {
+	// bash does not know anything about continuation lines and counts each of
+	// them as a separate command so the line has to be parsed again.
+	if context.continued && context.attachLine(text, `\`) {
+		return
+	}
+	if text == "" {
+		return
+	}
+
+	if bashTimestampRegexp.Match(text) {
+		parseTimestampLine(bashTimestampRegexp, text, context.current)
+		return
+	}
+
+	number := context.NextNumber()
+	if !context.Match(text) {
+		return
+	}
+
+	context.current.command = text
+	context.current.number = number
+	if strings.HasSuffix(text, `\`) {
+		context.continued = true
+	} else {
+		context.Commit()
+	}
+}
+
+func (bp *bashLinesParser) Finish(context *ParseContext) {
+}
+
+// IsRecordStart tells if the line is a separate command. Timestamp line
+// belongs to the next command and continued line is the part of the previous
+// one.
+func (bp *bashLinesParser) IsRecordStart(previous string, line string) bool {
+	return !strings.HasSuffix(previous, `\`) && !bashTimestampRegexp.Match(previous)
+}
+
+func (rp *recordsParser) Parse(context *ParseContext, text string) {
+	isTimestamp := rp.timestampRegexp.Match(text)
+
+	switch {
+	case !rp.seenTimestamp && rp.fallback != nil && (context.continued || !isTimestamp):
+		rp.fallback.Parse(context, text)
+	case isTimestamp:
+		rp.seenTimestamp = true
+		rp.Finish(context)
+		// the previous record is complete so parsing may be started again
+		// from this line.
+		context.markCheckpoint(context.lineOffset)
+		parseTimestampLine(rp.timestampRegexp, text, context.current)
+	case rp.pending:
+		utils.Logger.Info("Attach the line to the current record")
+		context.current.command += "\n" + text
+	default:
+		context.current.command = text
+		rp.pendingNumber = context.NextNumber()
+		rp.pending = true
+		if !rp.seenTimestamp {
+			rp.Finish(context)
+		}
+	}
+}
+
+func (rp *recordsParser) Finish(context *ParseContext) {
+	if !rp.pending {
+		return
+	}
+	rp.pending = false
+	// empty lines between the records are not a part of the command.
+	context.current.command = strings.TrimRight(context.current.command, "\n")
+
+	if context.Match(context.current.command) {
+		context.current.number = rp.pendingNumber
+		context.Commit()
+	}
+}
+
+// IsRecordStart tells if the line is a timestamp line. History without
+// timestamps has no boundaries so it is read from the beginning.
+func (rp *recordsParser) IsRecordStart(previous string, line string) bool {
+	return rp.timestampRegexp.Match(line)
+}
+
+func (rp *recordsParser) HasPending() bool {
+	return rp.pending
+}
+
+func parseTimestampLine(timestampRegexp *utils.Regexp, text string, currentEvent *HistoryEntry) {
+	groups, err := timestampRegexp.Groups(text)
+	if err == nil {
+		var converted int64
+		if converted, err = strconv.ParseInt(groups[0], 10, 64); err == nil {
+			utils.Logger.WithFields(logrus.Fields{
+				"timestamp": converted,
+			}).Info("Parse timestamp")
+			currentEvent.timestamp = converted
+			return
+		}
+	}
+
+	utils.Logger.WithFields(logrus.Fields{
+		"text":  text,
+		"error": err,
+	}).Warn("Cannot parse timestamp")
+}
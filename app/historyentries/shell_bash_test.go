@@ -0,0 +1,118 @@
+package historyentries
+
+import (
+	"testing"
+
+	"github.com/9seconds/ah/app/environments"
+)
+
+func TestBashLinesParser(t *testing.T) {
+	env := &environments.Environment{Shell: environments.ShellBash}
+	cases := []struct {
+		name     string
+		history  string
+		expected []expectedEntry
+	}{
+		{
+			name:    "plain",
+			history: "ls -la\n\nmake\n",
+			expected: []expectedEntry{
+				{1, "ls -la", 0},
+				{2, "make", 0},
+			},
+		},
+		{
+			name:    "timestamps",
+			history: "#1430000000\nls -la\n#1430000005\nmake\n",
+			expected: []expectedEntry{
+				{1, "ls -la", 1430000000},
+				{2, "make", 1430000005},
+			},
+		},
+		{
+			name:    "continued",
+			history: "echo a \\\nb\npwd\n",
+			expected: []expectedEntry{
+				{1, "echo a \\\nb", 0},
+				{2, "b", 0},
+				{3, "pwd", 0},
+			},
+		},
+		{
+			name:    "lithist lines",
+			history: "#1430000000\nfor a in 1 2; do\necho $a\ndone\n",
+			expected: []expectedEntry{
+				{1, "for a in 1 2; do", 1430000000},
+				{2, "echo $a", 0},
+				{3, "done", 0},
+			},
+		},
+	}
+
+	for _, testCase := range cases {
+		checkEntries(t, testCase.name, parseString(t, env, testCase.history), testCase.expected)
+	}
+}
+
+func TestBashRecordsParser(t *testing.T) {
+	env := &environments.Environment{
+		Shell:       environments.ShellBash,
+		BashRecords: environments.BashRecordsTimestamps,
+	}
+	cases := []struct {
+		name     string
+		history  string
+		expected []expectedEntry
+	}{
+		{
+			name:    "single lines",
+			history: "#1430000000\nls -la\n#1430000005\nmake\n",
+			expected: []expectedEntry{
+				{1, "ls -la", 1430000000},
+				{2, "make", 1430000005},
+			},
+		},
+		{
+			name:    "loop",
+			history: "#1430000000\nfor a in 1 2; do\necho $a\ndone\n#1430000005\nmake\n",
+			expected: []expectedEntry{
+				{1, "for a in 1 2; do\necho $a\ndone", 1430000000},
+				{2, "make", 1430000005},
+			},
+		},
+		{
+			name:    "heredoc with empty line",
+			history: "#1430000000\ncat <<EOF\na\n\nb\nEOF\n#1430000005\nmake\n",
+			expected: []expectedEntry{
+				{1, "cat <<EOF\na\n\nb\nEOF", 1430000000},
+				{2, "make", 1430000005},
+			},
+		},
+		{
+			name:    "empty lines between records",
+			history: "#1430000000\nls\n\n\n#1430000005\n\nmake\n\n",
+			expected: []expectedEntry{
+				{1, "ls", 1430000000},
+				{2, "make", 1430000005},
+			},
+		},
+		{
+			name:    "lines before timestamps",
+			history: "ls\npwd\n#1430000005\nmake\n",
+			expected: []expectedEntry{
+				{1, "ls", 0},
+				{2, "pwd", 0},
+				{3, "make", 1430000005},
+			},
+		},
+		{
+			name:     "pending on the end",
+			history:  "#1430000000\ncat <<EOF\n\nEOF\n",
+			expected: []expectedEntry{{1, "cat <<EOF\n\nEOF", 1430000000}},
+		},
+	}
+
+	for _, testCase := range cases {
+		checkEntries(t, testCase.name, parseString(t, env, testCase.history), testCase.expected)
+	}
+}
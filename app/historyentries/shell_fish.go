@@ -0,0 +1,117 @@
+package historyentries
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	logrus "github.com/Sirupsen/logrus"
+
+	"github.com/9seconds/ah/app/environments"
+	"github.com/9seconds/ah/app/utils"
+)
+
+var (
+	fishCmdRegexp  = utils.CreateRegexp(`^- cmd:\s*(.*?)$`)
+	fishWhenRegexp = utils.CreateRegexp(`\s*when:\s*(\d+)$`)
+)
+
+// fishParser parses fish history. Fish stores its history as a list of
+// YAML-like records where "- cmd:" line is followed by "when:" line and
+// optional "paths:" list so command and its timestamp are on different lines.
+// The parser keeps a number of the pending command and commits it on the
+// "when" line only: keepers check a number of the current event to stop so
+// event has to be complete before number is set.
+type fishParser struct {
+	pending       bool
+	pendingNumber uint
+}
+
+func init() {
+	RegisterShell(&Shell{
+		Name:       environments.ShellFish,
+		HistFile:   ".local/share/fish/fish_history",
+		NumberBase: 1,
+		Detect:     detectByNames([]string{"fish"}, []string{"fish_history"}),
+		NewParser: func(_ *environments.Environment) ShellSpecificParser {
+			return new(fishParser)
+		},
+	})
+}
+
+func (fp *fishParser) Parse(context *ParseContext, text string) {
+	if groups, err := fishCmdRegexp.Groups(text); err == nil {
+		if fp.pending {
+			utils.Logger.WithFields(logrus.Fields{
+				"event": context.current,
+			}).Warn("Previous command has no timestamp, commit it as is.")
+			fp.Finish(context)
+		}
+
+		command := unescapeFish(groups[0])
+		number := context.NextNumber()
+		if !context.Match(command) {
+			return
+		}
+
+		context.current.command = command
+		context.current.timestamp = 0
+		fp.pendingNumber = number
+		fp.pending = true
+	} else if groups, err := fishWhenRegexp.Groups(text); err == nil && fp.pending {
+		context.current.timestamp, _ = strconv.ParseInt(groups[0], 10, 64)
+		fp.Finish(context)
+	}
+}
+
+func (fp *fishParser) Finish(context *ParseContext) {
+	if !fp.pending {
+		return
+	}
+
+	context.current.number = fp.pendingNumber
+	fp.pending = false
+	context.Commit()
+}
+
+func (fp *fishParser) HasPending() bool {
+	return fp.pending
+}
+
+func (fp *fishParser) IsRecordStart(previous string, line string) bool {
+	return fishCmdRegexp.Match(line)
+}
+
+// unescapeFish converts a command line from the fish history format.
+// Fish escapes backslashes and newlines so multiline commands are stored
+// within a single line.
+func unescapeFish(text string) string {
+	if !strings.Contains(text, `\`) {
+		return text
+	}
+
+	buffer := new(bytes.Buffer)
+	escaped := false
+	for _, character := range text {
+		switch {
+		case escaped && character == 'n':
+			buffer.WriteRune('\n')
+		case escaped && character == '\\':
+			buffer.WriteRune('\\')
+		case escaped:
+			buffer.WriteRune('\\')
+			buffer.WriteRune(character)
+		case character == '\\':
+			escaped = true
+			continue
+		default:
+			buffer.WriteRune(character)
+		}
+		escaped = false
+	}
+	if escaped {
+		buffer.WriteRune('\\')
+	}
+
+	return buffer.String()
+}
@@ -0,0 +1,68 @@
+package historyentries
+
+import (
+	"testing"
+
+	"github.com/9seconds/ah/app/environments"
+)
+
+func TestFishParser(t *testing.T) {
+	env := &environments.Environment{Shell: environments.ShellFish}
+	cases := []struct {
+		name     string
+		history  string
+		expected []expectedEntry
+	}{
+		{
+			name:    "simple",
+			history: "- cmd: ls -la\n  when: 1430000000\n- cmd: pwd\n  when: 1430000001\n",
+			expected: []expectedEntry{
+				{1, "ls -la", 1430000000},
+				{2, "pwd", 1430000001},
+			},
+		},
+		{
+			name:     "paths",
+			history:  "- cmd: cat /tmp/file\n  when: 1430000000\n  paths:\n    - /tmp/file\n",
+			expected: []expectedEntry{{1, "cat /tmp/file", 1430000000}},
+		},
+		{
+			name:     "escaped",
+			history:  "- cmd: echo a\\nb \\\\n\n  when: 1430000000\n",
+			expected: []expectedEntry{{1, "echo a\nb \\n", 1430000000}},
+		},
+		{
+			name:    "no timestamp",
+			history: "- cmd: ls\n- cmd: pwd\n  when: 1430000001\n",
+			expected: []expectedEntry{
+				{1, "ls", 0},
+				{2, "pwd", 1430000001},
+			},
+		},
+		{
+			name:     "pending on the end",
+			history:  "- cmd: ls\n  when: 1430000000\n- cmd: pwd\n",
+			expected: []expectedEntry{{1, "ls", 1430000000}, {2, "pwd", 0}},
+		},
+	}
+
+	for _, testCase := range cases {
+		checkEntries(t, testCase.name, parseString(t, env, testCase.history), testCase.expected)
+	}
+}
+
+func TestUnescapeFish(t *testing.T) {
+	cases := map[string]string{
+		`ls`:         "ls",
+		`echo a\nb`:  "echo a\nb",
+		`echo \\n`:   `echo \n`,
+		`echo \t`:    `echo \t`,
+		`trailing \`: `trailing \`,
+	}
+
+	for escaped, expected := range cases {
+		if actual := unescapeFish(escaped); actual != expected {
+			t.Errorf("unescapeFish(%q) = %q, expected %q", escaped, actual, expected)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package historyentries
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+
+	"github.com/9seconds/ah/app/environments"
+)
+
+// Markers of binary ksh history files.
+const (
+	ksh93Undo  = 0x81
+	ksh93CmdNo = 0x82
+	// ksh93MarkerSize is the size of the command number marker: 0x82, NUL,
+	// 3 bytes of the number and NUL.
+	ksh93MarkerSize = 6
+
+	mkshMagic0    = 0xab
+	mkshMagic1    = 0xcd
+	mkshRecordTag = 0xff
+)
+
+// kshParser parses a history of Korn shell family. ksh93 and mksh use
+// binary history files where commands are separated by NUL bytes: ksh93
+// file starts with 0x81 0x01 magic and may have 0x82 command number markers,
+// mksh file starts with 0xab 0xcd magic and each record is prefixed by 0xff
+// and 4 bytes of the line number. pdksh and its descendants write plain text
+// files, one command per line.
+type kshParser struct {
+	detected bool
+	binary   bool
+	mksh     bool
+}
+
+func init() {
+	RegisterShell(&Shell{
+		Name:       environments.ShellKsh,
+		HistFile:   ".sh_history",
+		NumberBase: 1,
+		Detect: detectByNames(
+			[]string{"ksh", "ksh93", "pdksh", "oksh", "lksh"},
+			[]string{".sh_history", ".ksh_history"}),
+		NewParser: func(_ *environments.Environment) ShellSpecificParser {
+			return new(kshParser)
+		},
+	})
+	RegisterShell(&Shell{
+		Name:       environments.ShellMksh,
+		HistFile:   ".mksh_history",
+		NumberBase: 1,
+		Detect:     detectByNames([]string{"mksh"}, []string{".mksh_history"}),
+		NewParser: func(_ *environments.Environment) ShellSpecificParser {
+			return new(kshParser)
+		},
+	})
+}
+
+// DetectFormat detects a format of the history file by its first bytes.
+func (kp *kshParser) DetectFormat(header []byte) {
+	kp.detected = true
+	if len(header) >= 2 {
+		kp.mksh = header[0] == mkshMagic0 && header[1] == mkshMagic1
+		kp.binary = kp.mksh || header[0] == ksh93Undo && header[1] == 0x01
+	}
+}
+
+// Split detects a format of the history file by its first bytes and
+// splits it either by NUL bytes or by newlines.
+func (kp *kshParser) Split(data []byte, atEOF bool) (int, []byte, error) {
+	magicLength := 0
+	if !kp.detected {
+		if len(data) < 2 && !atEOF {
+			return 0, nil, nil
+		}
+		kp.DetectFormat(data)
+		if kp.binary {
+			magicLength = 2
+		}
+	}
+
+	if !kp.binary {
+		return bufio.ScanLines(data, atEOF)
+	}
+
+	// record markers may contain NUL bytes so they are skipped before
+	// searching for the end of the record.
+	start := magicLength
+	switch {
+	case len(data) <= start:
+	case kp.mksh && data[start] == mkshRecordTag:
+		start += 5
+	case !kp.mksh && data[start] == ksh93CmdNo:
+		start += ksh93MarkerSize
+	}
+
+	if start <= len(data) {
+		if idx := bytes.IndexByte(data[start:], 0); idx >= 0 {
+			return start + idx + 1, data[start : start+idx], nil
+		}
+	}
+	if atEOF {
+		if start < len(data) {
+			return len(data), data[start:], nil
+		}
+		return len(data), nil, nil
+	}
+
+	// request more data. Magic is consumed anyway because it is checked
+	// only once.
+	return magicLength, nil, nil
+}
+
+func (kp *kshParser) Parse(context *ParseContext, text string) {
+	if kp.binary {
+		if !kp.mksh && text[0] == ksh93Undo {
+			return
+		}
+		text = strings.TrimRight(text, "\n")
+	}
+
+	number := context.NextNumber()
+	if !context.Match(text) {
+		return
+	}
+
+	context.current.command = text
+	context.current.number = number
+	context.Commit()
+}
+
+// IsRecordStart tells if the line is a separate command. Binary history has
+// no lines so it is read from the beginning.
+func (kp *kshParser) IsRecordStart(previous string, line string) bool {
+	return !kp.binary
+}
+
+func (kp *kshParser) Finish(context *ParseContext) {
+}
@@ -0,0 +1,83 @@
+package historyentries
+
+import (
+	"testing"
+
+	"github.com/9seconds/ah/app/environments"
+)
+
+func TestKshParser(t *testing.T) {
+	cases := []struct {
+		name     string
+		shell    string
+		history  string
+		expected []expectedEntry
+	}{
+		{
+			name:    "plain",
+			shell:   environments.ShellKsh,
+			history: "ls -la\nmake\n",
+			expected: []expectedEntry{
+				{1, "ls -la", 0},
+				{2, "make", 0},
+			},
+		},
+		{
+			name:    "ksh93",
+			shell:   environments.ShellKsh,
+			history: "\x81\x01ls -la\n\x00make\n\x00",
+			expected: []expectedEntry{
+				{1, "ls -la", 0},
+				{2, "make", 0},
+			},
+		},
+		{
+			name:  "ksh93 markers",
+			shell: environments.ShellKsh,
+			history: "\x81\x01\x82\x00\x00\x00\x01\x00ls -la\n\x00\x81undo\x00" +
+				"\x82\x00\x00\x01\x2c\x00for a in 1 2\ndo echo $a\ndone\n\x00" +
+				"\x82\x00\x01\x00\x00\x00make\n\x00",
+			expected: []expectedEntry{
+				{1, "ls -la", 0},
+				{2, "for a in 1 2\ndo echo $a\ndone", 0},
+				{3, "make", 0},
+			},
+		},
+		{
+			name:    "mksh",
+			shell:   environments.ShellMksh,
+			history: "\xab\xcd\xff\x00\x00\x00\x01ls -la\x00\xff\x00\x00\x00\x02make\x00",
+			expected: []expectedEntry{
+				{1, "ls -la", 0},
+				{2, "make", 0},
+			},
+		},
+	}
+
+	for _, testCase := range cases {
+		env := &environments.Environment{Shell: testCase.shell}
+		checkEntries(t, testCase.name, parseString(t, env, testCase.history), testCase.expected)
+	}
+}
+
+func TestKshDetectFormat(t *testing.T) {
+	cases := []struct {
+		header []byte
+		binary bool
+		mksh   bool
+	}{
+		{[]byte("ls"), false, false},
+		{[]byte{ksh93Undo, 0x01}, true, false},
+		{[]byte{mkshMagic0, mkshMagic1}, true, true},
+		{[]byte{}, false, false},
+	}
+
+	for _, testCase := range cases {
+		parser := new(kshParser)
+		parser.DetectFormat(testCase.header)
+		if parser.binary != testCase.binary || parser.mksh != testCase.mksh {
+			t.Errorf("DetectFormat(%q): binary=%t mksh=%t, expected binary=%t mksh=%t",
+				testCase.header, parser.binary, parser.mksh, testCase.binary, testCase.mksh)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package historyentries
+
+import (
+	"strings"
+
+	"github.com/9seconds/ah/app/environments"
+)
+
+// powerShellContinuation is a marker PSReadLine puts at the end of all
+// lines of the multiline command but the last one.
+const powerShellContinuation = "`"
+
+// powerShellParser parses PSReadLine history file. It has no timestamps,
+// only commands one per line.
+type powerShellParser struct{}
+
+func init() {
+	RegisterShell(&Shell{
+		Name:       environments.ShellPowerShell,
+		HistFile:   ".local/share/powershell/PSReadLine/ConsoleHost_history.txt",
+		NumberBase: 1,
+		Detect: detectByNames(
+			[]string{"pwsh", "powershell"},
+			[]string{"ConsoleHost_history.txt"}),
+		NewParser: func(_ *environments.Environment) ShellSpecificParser {
+			return new(powerShellParser)
+		},
+	})
+}
+
+func (pp *powerShellParser) Parse(context *ParseContext, text string) {
+	if context.continued {
+		context.attachLine(text, powerShellContinuation)
+		return
+	}
+
+	number := context.NextNumber()
+	if !context.Match(text) {
+		return
+	}
+
+	context.current.command = text
+	context.current.number = number
+	if strings.HasSuffix(text, powerShellContinuation) {
+		context.continued = true
+	} else {
+		context.Commit()
+	}
+}
+
+func (pp *powerShellParser) IsRecordStart(previous string, line string) bool {
+	return !strings.HasSuffix(previous, powerShellContinuation)
+}
+
+func (pp *powerShellParser) Finish(context *ParseContext) {
+}
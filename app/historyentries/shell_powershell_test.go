@@ -0,0 +1,37 @@
+package historyentries
+
+import (
+	"testing"
+
+	"github.com/9seconds/ah/app/environments"
+)
+
+func TestPowerShellParser(t *testing.T) {
+	env := &environments.Environment{Shell: environments.ShellPowerShell}
+	cases := []struct {
+		name     string
+		history  string
+		expected []expectedEntry
+	}{
+		{
+			name:    "plain",
+			history: "Get-ChildItem\r\nGet-Process\r\n",
+			expected: []expectedEntry{
+				{1, "Get-ChildItem", 0},
+				{2, "Get-Process", 0},
+			},
+		},
+		{
+			name:    "multiline",
+			history: "if ($a) {`\n  echo 1`\n}\nGet-Process\n",
+			expected: []expectedEntry{
+				{1, "if ($a) {`\n  echo 1`\n}", 0},
+				{2, "Get-Process", 0},
+			},
+		},
+	}
+
+	for _, testCase := range cases {
+		checkEntries(t, testCase.name, parseString(t, env, testCase.history), testCase.expected)
+	}
+}
@@ -0,0 +1,21 @@
+package historyentries
+
+import (
+	"github.com/9seconds/ah/app/environments"
+	"github.com/9seconds/ah/app/utils"
+)
+
+var tcshTimestampRegexp = utils.CreateRegexp(`^#\+(\d+)$`)
+
+func init() {
+	// tcsh with savehist writes "#+<timestamp>" line before each command.
+	RegisterShell(&Shell{
+		Name:       environments.ShellTcsh,
+		HistFile:   ".history",
+		NumberBase: 1,
+		Detect:     detectByNames([]string{"tcsh", "csh"}, []string{".history"}),
+		NewParser: func(_ *environments.Environment) ShellSpecificParser {
+			return &recordsParser{timestampRegexp: tcshTimestampRegexp}
+		},
+	})
+}
@@ -0,0 +1,45 @@
+package historyentries
+
+import (
+	"testing"
+
+	"github.com/9seconds/ah/app/environments"
+)
+
+func TestTcshParser(t *testing.T) {
+	env := &environments.Environment{Shell: environments.ShellTcsh}
+	cases := []struct {
+		name     string
+		history  string
+		expected []expectedEntry
+	}{
+		{
+			name:    "timestamps",
+			history: "#+1430000000\nls -la\n#+1430000005\nmake\n",
+			expected: []expectedEntry{
+				{1, "ls -la", 1430000000},
+				{2, "make", 1430000005},
+			},
+		},
+		{
+			name:    "multiline",
+			history: "#+1430000000\nforeach a (1 2)\necho $a\nend\n#+1430000005\nmake\n",
+			expected: []expectedEntry{
+				{1, "foreach a (1 2)\necho $a\nend", 1430000000},
+				{2, "make", 1430000005},
+			},
+		},
+		{
+			name:    "no timestamps",
+			history: "ls -la\nmake\n",
+			expected: []expectedEntry{
+				{1, "ls -la", 0},
+				{2, "make", 0},
+			},
+		},
+	}
+
+	for _, testCase := range cases {
+		checkEntries(t, testCase.name, parseString(t, env, testCase.history), testCase.expected)
+	}
+}
@@ -0,0 +1,149 @@
+package historyentries
+
+import (
+	"strconv"
+	"strings"
+
+	logrus "github.com/Sirupsen/logrus"
+
+	"github.com/9seconds/ah/app/environments"
+	"github.com/9seconds/ah/app/utils"
+)
+
+// zshMeta is a marker of metafied byte in zsh history file.
+const zshMeta = 0x83
+
+var (
+	zshLineRegexp       = utils.CreateRegexp(`^: (\d+):(\d+);(.*?)$`)
+	zshRecordRegexp     = utils.CreateRegexp(`^: \d+:\d+;`)
+	zshTornHeaderRegexp = utils.CreateRegexp(`^: \d*(:\d*)?$`)
+	zshTornRecordRegexp = utils.CreateRegexp(`^: \d*(:\d*;?)?$`)
+)
+
+type zshParser struct{}
+
+func init() {
+	RegisterShell(&Shell{
+		Name:       environments.ShellZsh,
+		HistFile:   ".zsh_history",
+		NumberBase: 1,
+		Detect:     detectByNames([]string{"zsh"}, []string{".zsh_history", ".zhistory"}),
+		NewParser: func(_ *environments.Environment) ShellSpecificParser {
+			return new(zshParser)
+		},
+	})
+}
+
+// Decode decodes a line written by zsh. zsh escapes some bytes
+// (basically, the most of non-ASCII ones) with Meta byte followed by
+// the original byte xored with 32.
+func (zp *zshParser) Decode(text string) string {
+	idx := strings.IndexByte(text, zshMeta)
+	if idx < 0 {
+		return text
+	}
+
+	decoded := []byte(text[:idx])
+	for ; idx < len(text); idx++ {
+		if text[idx] == zshMeta && idx+1 < len(text) {
+			idx++
+			decoded = append(decoded, text[idx]^32)
+		} else {
+			decoded = append(decoded, text[idx])
+		}
+	}
+
+	return string(decoded)
+}
+
+// Parse parses a line of zsh history. With SHARE_HISTORY several shells
+// append to the history file simultaneously so records may be interleaved
+// within a single line. Such line is splitted into separate records.
+func (zp *zshParser) Parse(context *ParseContext, text string) {
+	if context.continued {
+		context.attachLine(text, `\`)
+		return
+	}
+
+	records := splitZshRecords(text)
+	if len(records) > 1 {
+		utils.Logger.WithFields(logrus.Fields{
+			"records": len(records),
+		}).Warn("Line contains interleaved records, split them.")
+	}
+
+	for idx := 0; idx < len(records) && context.Continue(); idx++ {
+		parseZshRecord(context, records[idx], idx == len(records)-1)
+	}
+}
+
+func (zp *zshParser) Finish(context *ParseContext) {
+}
+
+// IsRecordStart tells if the line is not a continuation of the previous
+// one. Interleaved records are parsed within a line so they do not matter.
+func (zp *zshParser) IsRecordStart(previous string, line string) bool {
+	return !strings.HasSuffix(previous, `\`)
+}
+
+// parseZshRecord parses a single record of zsh history. Records without
+// extended history header are the commands written without EXTENDED_HISTORY
+// option or the tails of torn records. zsh treats them as separate commands
+// so they are numbered the same way but do not have a timestamp. Only the last
+// record of the line may be continued on the next one.
+func parseZshRecord(context *ParseContext, text string, last bool) {
+	if zshTornHeaderRegexp.Match(text) {
+		utils.Logger.WithFields(logrus.Fields{
+			"text": text,
+		}).Warn("Torn record header, skip.")
+		return
+	}
+
+	var timestamp, elapsed int64
+	hasElapsed := false
+	command := text
+
+	if groups, err := zshLineRegexp.Groups(text); err == nil {
+		timestamp, _ = strconv.ParseInt(groups[0], 10, 64)
+		elapsed, _ = strconv.ParseInt(groups[1], 10, 64)
+		hasElapsed = true
+		command = groups[2]
+	} else {
+		utils.Logger.WithFields(logrus.Fields{
+			"text": text,
+		}).Warn("Record has no extended history header, use it as is.")
+	}
+
+	number := context.NextNumber()
+	if !context.Match(command) {
+		return
+	}
+
+	context.current.command = command
+	context.current.number = number
+	context.current.timestamp = timestamp
+	context.current.elapsed = elapsed
+	context.current.hasElapsed = hasElapsed
+
+	if last && strings.HasSuffix(text, `\`) {
+		context.continued = true
+	} else {
+		context.Commit()
+	}
+}
+
+// splitZshRecords splits a line into the list of records. Line is split
+// only where the text before the record is a header which was torn by
+// another shell, so commands which contain something like header are kept
+// as is.
+func splitZshRecords(text string) (records []string) {
+	start := 0
+	for idx := 1; idx < len(text); idx++ {
+		if text[idx] == ':' && zshTornRecordRegexp.Match(text[start:idx]) && zshRecordRegexp.Match(text[idx:]) {
+			records = append(records, text[start:idx])
+			start = idx
+		}
+	}
+
+	return append(records, text[start:])
+}
@@ -0,0 +1,93 @@
+package historyentries
+
+import (
+	"testing"
+
+	"github.com/9seconds/ah/app/environments"
+)
+
+func TestZshParser(t *testing.T) {
+	env := &environments.Environment{Shell: environments.ShellZsh}
+	cases := []struct {
+		name     string
+		history  string
+		expected []expectedEntry
+	}{
+		{
+			name:    "extended",
+			history: ": 1430000000:0;ls -la\n: 1430000005:3;make\n",
+			expected: []expectedEntry{
+				{1, "ls -la", 1430000000},
+				{2, "make", 1430000005},
+			},
+		},
+		{
+			name:    "plain",
+			history: "ls -la\nmake\n",
+			expected: []expectedEntry{
+				{1, "ls -la", 0},
+				{2, "make", 0},
+			},
+		},
+		{
+			name:    "continued",
+			history: ": 1430000000:0;echo a \\\nb\n: 1430000001:0;pwd\n",
+			expected: []expectedEntry{
+				{1, "echo a \\\nb", 1430000000},
+				{2, "pwd", 1430000001},
+			},
+		},
+		{
+			name:     "metafied",
+			history:  ": 1430000000:0;echo \xd0\x83\xb0\n",
+			expected: []expectedEntry{{1, "echo \xd0\x90", 1430000000}},
+		},
+		{
+			name:    "interleaved",
+			history: ": 1430000000:0;: 1430000001:0;pwd\nls\n",
+			expected: []expectedEntry{
+				{1, "", 1430000000},
+				{2, "pwd", 1430000001},
+				{3, "ls", 0},
+			},
+		},
+		{
+			name:    "torn header",
+			history: ": 14300: 1430000001:0;pwd\n",
+			expected: []expectedEntry{
+				{1, "pwd", 1430000001},
+			},
+		},
+		{
+			name:     "header in command",
+			history:  ": 1430000000:0;echo \": 1:0;x\"\n",
+			expected: []expectedEntry{{1, `echo ": 1:0;x"`, 1430000000}},
+		},
+		{
+			name:     "header in plain command",
+			history:  "echo : 1:0;x\n",
+			expected: []expectedEntry{{1, "echo : 1:0;x", 0}},
+		},
+	}
+
+	for _, testCase := range cases {
+		checkEntries(t, testCase.name, parseString(t, env, testCase.history), testCase.expected)
+	}
+}
+
+func TestZshDecode(t *testing.T) {
+	parser := new(zshParser)
+	cases := map[string]string{
+		"ls":                 "ls",
+		"\xd0\x83\xbf":       "\xd0\x9f",
+		"echo \xf0\x83\xbf":  "echo \xf0\x9f",
+		"trailing \x83":      "trailing \x83",
+		"\x83\xa0\x83\xa3ok": "\x80\x83ok",
+	}
+
+	for encoded, expected := range cases {
+		if actual := parser.Decode(encoded); actual != expected {
+			t.Errorf("Decode(%q) = %q, expected %q", encoded, actual, expected)
+		}
+	}
+}
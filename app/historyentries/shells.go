@@ -0,0 +1,94 @@
+package historyentries
+
+import (
+	"fmt"
+	"path/filepath"
+
+	logrus "github.com/Sirupsen/logrus"
+
+	"github.com/9seconds/ah/app/environments"
+	"github.com/9seconds/ah/app/utils"
+)
+
+// Shell describes how to read a history of the shell.
+type Shell struct {
+	// Name is a code name of the shell, the same as --shell option has.
+	Name string
+	// HistFile is a default path to the history file relative to the home directory.
+	HistFile string
+	// NumberBase is a number of the first command in the history file.
+	NumberBase uint
+	// Detect tells if environment belongs to the shell. It is used only if
+	// there is no shell registered with the name from the environment.
+	Detect func(*environments.Environment) bool
+	// NewParser creates a parser of the history file.
+	NewParser func(*environments.Environment) ShellSpecificParser
+}
+
+var shells []*Shell
+
+// RegisterShell adds the shell into the registry. Shells are detected in
+// the order of registration.
+func RegisterShell(shell *Shell) {
+	shells = append(shells, shell)
+}
+
+// GetHistFileName returns filename of the history file or error if something goes wrong (e.g unsupported shell).
+func GetHistFileName(env *environments.Environment) (fileName string, err error) {
+	fileName = env.HistFile
+
+	if fileName == "" {
+		var shell *Shell
+		if shell, err = getShell(env); err == nil {
+			fileName = filepath.Join(env.HomeDir, shell.HistFile)
+		}
+	}
+
+	return
+}
+
+func getShell(env *environments.Environment) (*Shell, error) {
+	for _, shell := range shells {
+		if shell.Name == env.Shell {
+			return shell, nil
+		}
+	}
+
+	for _, shell := range shells {
+		if shell.Detect != nil && shell.Detect(env) {
+			utils.Logger.WithFields(logrus.Fields{
+				"shell":    env.Shell,
+				"histfile": env.HistFile,
+				"detected": shell.Name,
+			}).Info("Detect shell")
+			return shell, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Shell %s is not supported", env.Shell)
+}
+
+// detectByNames returns a detection heuristic which checks the name of
+// the shell executable and the name of the history file.
+func detectByNames(shellNames []string, histFileNames []string) func(*environments.Environment) bool {
+	return func(env *environments.Environment) bool {
+		shellName := filepath.Base(env.Shell)
+		for _, name := range shellNames {
+			if shellName == name {
+				return true
+			}
+		}
+
+		if env.HistFile == "" {
+			return false
+		}
+		histFileName := filepath.Base(env.HistFile)
+		for _, name := range histFileNames {
+			if histFileName == name {
+				return true
+			}
+		}
+
+		return false
+	}
+}
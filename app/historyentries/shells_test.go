@@ -0,0 +1,38 @@
+package historyentries
+
+import (
+	"testing"
+
+	"github.com/9seconds/ah/app/environments"
+)
+
+func TestGetShell(t *testing.T) {
+	cases := []struct {
+		shell    string
+		histFile string
+		expected string
+	}{
+		{environments.ShellZsh, "", environments.ShellZsh},
+		{"/bin/bash", "", environments.ShellBash},
+		{"/usr/local/bin/fish", "", environments.ShellFish},
+		{"/bin/mksh", "", environments.ShellMksh},
+		{"/bin/ksh93", "", environments.ShellKsh},
+		{"/bin/csh", "", environments.ShellTcsh},
+		{"/usr/bin/pwsh", "", environments.ShellPowerShell},
+		{"/bin/sh", "/home/user/.zhistory", environments.ShellZsh},
+		{"/bin/sh", "/home/user/.history", environments.ShellTcsh},
+	}
+
+	for _, testCase := range cases {
+		shell, err := getShell(&environments.Environment{Shell: testCase.shell, HistFile: testCase.histFile})
+		if err != nil {
+			t.Errorf("%s %s: %v", testCase.shell, testCase.histFile, err)
+		} else if shell.Name != testCase.expected {
+			t.Errorf("%s %s: detected %s, expected %s", testCase.shell, testCase.histFile, shell.Name, testCase.expected)
+		}
+	}
+
+	if _, err := getShell(&environments.Environment{Shell: "/bin/sh"}); err == nil {
+		t.Error("Unknown shell is detected")
+	}
+}
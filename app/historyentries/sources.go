@@ -0,0 +1,135 @@
+package historyentries
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	logrus "github.com/Sirupsen/logrus"
+
+	"github.com/9seconds/ah/app/environments"
+	"github.com/9seconds/ah/app/utils"
+)
+
+// referenceSeparator separates source label and history number in the
+// reference to the history entry.
+const referenceSeparator = ":"
+
+type entriesByTimestamp []HistoryEntry
+
+func (ebt entriesByTimestamp) Len() int {
+	return len(ebt)
+}
+
+func (ebt entriesByTimestamp) Less(i, j int) bool {
+	return ebt[i].timestamp < ebt[j].timestamp
+}
+
+func (ebt entriesByTimestamp) Swap(i, j int) {
+	ebt[i], ebt[j] = ebt[j], ebt[i]
+}
+
+// GetMergedCommands returns commands from all history sources of the
+// environment merged by their timestamps. Each entry is labeled with its
+// source.
+func GetMergedCommands(filter Filter, conditions []Condition, env *environments.Environment) ([]HistoryEntry, error) {
+	var merged []HistoryEntry
+
+	for _, source := range env.GetHistorySources() {
+		keeper, err := GetCommands(GetCommandsAll, filter, conditions, env.ForSource(source))
+		if err != nil {
+			return nil, fmt.Errorf("Cannot read history %s: %v", source.Label, err)
+		}
+
+		entries := keeper.Result().([]HistoryEntry)
+		for idx := range entries {
+			entries[idx].source = source.Label
+		}
+		utils.Logger.WithFields(logrus.Fields{
+			"source":  source.Label,
+			"entries": len(entries),
+		}).Info("Read history source")
+
+		merged = append(merged, entries...)
+	}
+	sort.Stable(entriesByTimestamp(merged))
+
+	return merged, nil
+}
+
+// GetCommandByReference returns the history entry by its reference (see
+// HistoryEntry.GetReference) and the environment of its source. A reference
+// without a label is allowed only if there is the only history source.
+func GetCommandByReference(reference string, env *environments.Environment) (entry HistoryEntry, sourceEnv *environments.Environment, err error) {
+	label := ""
+	numberPart := reference
+	if idx := strings.LastIndex(reference, referenceSeparator); idx >= 0 {
+		label, numberPart = reference[:idx], reference[idx+1:]
+	}
+
+	number, err := strconv.Atoi(numberPart)
+	if err != nil || number < 0 {
+		err = fmt.Errorf("Cannot convert %s to a command number", reference)
+		return
+	}
+
+	source, err := getSource(label, env)
+	if err != nil {
+		return
+	}
+	sourceEnv = env.ForSource(source)
+
+	keeper, err := GetCommands(GetCommandsPrecise, nil, nil, sourceEnv, number)
+	if err != nil {
+		return
+	}
+
+	entry = keeper.Result().(HistoryEntry)
+	if entry.number != uint(number) {
+		err = fmt.Errorf("Command %s does not exist", reference)
+		return
+	}
+	entry.source = source.Label
+	traceName := entry.GetTraceName()
+	if stat, statErr := os.Stat(env.GetTraceFileName(traceName)); statErr == nil {
+		entry.hasHistory = true
+		entry.traceSize = stat.Size()
+		entry.traceHeader = readTraceHeader(env, traceName)
+	}
+
+	return
+}
+
+// IsReference tells if the argument looks like a reference to the history entry.
+func IsReference(argument string) bool {
+	if idx := strings.LastIndex(argument, referenceSeparator); idx >= 0 {
+		argument = argument[idx+1:]
+	}
+	_, err := strconv.Atoi(argument)
+
+	return err == nil
+}
+
+func getSource(label string, env *environments.Environment) (source environments.HistorySource, err error) {
+	sources := env.GetHistorySources()
+
+	if label == "" {
+		if len(sources) > 1 {
+			err = fmt.Errorf("There are %d history sources, please prefix the number with a source label", len(sources))
+		} else {
+			source = sources[0]
+		}
+		return
+	}
+
+	for _, source = range sources {
+		if source.Label == label {
+			return
+		}
+	}
+	err = fmt.Errorf("Unknown history source %s", label)
+
+	return
+}
@@ -0,0 +1,76 @@
+package historyentries
+
+import (
+	"fmt"
+
+	"github.com/9seconds/ah/app/environments"
+)
+
+// UniqueEntry is a history entry which represents all occurrences of the
+// same command. Entry itself is the most recent occurrence.
+type UniqueEntry struct {
+	HistoryEntry
+	count          int
+	firstTimestamp int64
+}
+
+// CollapseEntries collapses identical commands into their most recent
+// occurrences. Order of the entries is kept: collapsed entry is placed where
+// its most recent occurrence is.
+func CollapseEntries(entries []HistoryEntry) []UniqueEntry {
+	indexes := make(map[string]int)
+	var unique []UniqueEntry
+
+	for idx := len(entries) - 1; idx >= 0; idx-- {
+		entry := entries[idx]
+		if uniqueIdx, ok := indexes[entry.command]; ok {
+			collapsed := &unique[uniqueIdx]
+			collapsed.count++
+			collapsed.firstTimestamp = entry.timestamp
+			collapsed.hasHistory = collapsed.hasHistory || entry.hasHistory
+			collapsed.traceSize += entry.traceSize
+			if collapsed.traceHeader == nil {
+				collapsed.traceHeader = entry.traceHeader
+			}
+			continue
+		}
+
+		indexes[entry.command] = len(unique)
+		unique = append(unique, UniqueEntry{HistoryEntry: entry, count: 1, firstTimestamp: entry.timestamp})
+	}
+
+	for left, right := 0, len(unique)-1; left < right; left, right = left+1, right-1 {
+		unique[left], unique[right] = unique[right], unique[left]
+	}
+
+	return unique
+}
+
+// GetCount returns how many times the command was executed.
+func (ue UniqueEntry) GetCount() int {
+	return ue.count
+}
+
+// GetFirstTimestamp returns a timestamp of the first occurrence of the command.
+func (ue UniqueEntry) GetFirstTimestamp() int64 {
+	return ue.firstTimestamp
+}
+
+// ToString converts unique entry to the string representation according to the environment setting.
+// Times of the first and the last occurrences are rendered if they differ.
+func (ue UniqueEntry) ToString(env *environments.Environment, showDuration bool) string {
+	return ue.formatLine(env, showDuration, fmt.Sprintf(" %4dx", ue.count), ue.getFormattedTimes(env), ue.command)
+}
+
+// getFormattedTimes returns formatted times of the first and the last
+// occurrences. The first one is omitted if it is the same.
+func (ue UniqueEntry) getFormattedTimes(env *environments.Environment) string {
+	lastSeen := env.FormatTimeStamp(ue.timestamp)
+	if lastSeen == "" {
+		return ""
+	}
+	if firstSeen := env.FormatTimeStamp(ue.firstTimestamp); firstSeen != "" && firstSeen != lastSeen {
+		return firstSeen + " - " + lastSeen
+	}
+	return lastSeen
+}
@@ -0,0 +1,214 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Formats of the output.
+const (
+	// FormatText is a human readable output.
+	FormatText = "text"
+	// FormatJSON is a JSON array of records.
+	FormatJSON = "json"
+	// FormatNDJSON is a stream of JSON records, one per line.
+	FormatNDJSON = "ndjson"
+	// FormatCSV is a CSV table with a header.
+	FormatCSV = "csv"
+)
+
+// Formats is a list of all supported formats.
+var Formats = []string{FormatText, FormatJSON, FormatNDJSON, FormatCSV}
+
+// Field is a named value of the record.
+type Field struct {
+	Name  string
+	Value interface{}
+}
+
+// Record is an item of the output. Text is how record looks like in text
+// format, fields are used by structured formats in the given order.
+type Record struct {
+	Text   string
+	Fields []Field
+}
+
+// Writer writes records in some format. It has to be closed to finish
+// the output.
+type Writer interface {
+	Write(*Record) error
+	Close() error
+}
+
+type textWriter struct {
+	output io.Writer
+}
+
+type jsonWriter struct {
+	output  io.Writer
+	records int
+}
+
+type ndjsonWriter struct {
+	output io.Writer
+}
+
+type csvWriter struct {
+	output      *csv.Writer
+	wroteHeader bool
+}
+
+// IsSupported tells if format is supported.
+func IsSupported(format string) bool {
+	for _, supported := range Formats {
+		if format == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// NewWriter returns a writer of the records in the given format.
+func NewWriter(format string, output io.Writer) (Writer, error) {
+	switch format {
+	case FormatText:
+		return &textWriter{output: output}, nil
+	case FormatJSON:
+		return &jsonWriter{output: output}, nil
+	case FormatNDJSON:
+		return &ndjsonWriter{output: output}, nil
+	case FormatCSV:
+		return &csvWriter{output: csv.NewWriter(output)}, nil
+	}
+
+	return nil, fmt.Errorf("Unknown output format %s, please use one of %s", format, strings.Join(Formats, ", "))
+}
+
+func (tw *textWriter) Write(record *Record) (err error) {
+	if record.Text == "" {
+		return
+	}
+
+	if _, err = io.WriteString(tw.output, record.Text); err == nil && !strings.HasSuffix(record.Text, "\n") {
+		_, err = io.WriteString(tw.output, "\n")
+	}
+	return
+}
+
+func (tw *textWriter) Close() error {
+	return nil
+}
+
+func (jw *jsonWriter) Write(record *Record) error {
+	encoded, err := encodeJSON(record)
+	if err != nil {
+		return err
+	}
+
+	separator := ",\n"
+	if jw.records == 0 {
+		separator = "[\n"
+	}
+	jw.records++
+
+	_, err = io.WriteString(jw.output, separator+encoded)
+	return err
+}
+
+func (jw *jsonWriter) Close() (err error) {
+	if jw.records == 0 {
+		_, err = io.WriteString(jw.output, "[]\n")
+	} else {
+		_, err = io.WriteString(jw.output, "\n]\n")
+	}
+	return
+}
+
+func (nw *ndjsonWriter) Write(record *Record) error {
+	encoded, err := encodeJSON(record)
+	if err == nil {
+		_, err = io.WriteString(nw.output, encoded+"\n")
+	}
+	return err
+}
+
+func (nw *ndjsonWriter) Close() error {
+	return nil
+}
+
+// Write writes a record as a CSV row. Header is taken from the fields of
+// the first record so all records have to have the same fields.
+func (cw *csvWriter) Write(record *Record) error {
+	if !cw.wroteHeader {
+		header := make([]string, len(record.Fields))
+		for idx, field := range record.Fields {
+			header[idx] = field.Name
+		}
+		if err := cw.output.Write(header); err != nil {
+			return err
+		}
+		cw.wroteHeader = true
+	}
+
+	row := make([]string, len(record.Fields))
+	for idx, field := range record.Fields {
+		value, err := encodeCSVValue(field.Value)
+		if err != nil {
+			return err
+		}
+		row[idx] = value
+	}
+
+	return cw.output.Write(row)
+}
+
+func (cw *csvWriter) Close() error {
+	cw.output.Flush()
+	return cw.output.Error()
+}
+
+// encodeJSON encodes record as JSON object keeping the order of fields.
+func encodeJSON(record *Record) (string, error) {
+	buffer := new(bytes.Buffer)
+
+	buffer.WriteString("{")
+	for idx, field := range record.Fields {
+		if idx > 0 {
+			buffer.WriteString(",")
+		}
+		name, _ := json.Marshal(field.Name)
+		value, err := json.Marshal(field.Value)
+		if err != nil {
+			return "", err
+		}
+		buffer.Write(name)
+		buffer.WriteString(":")
+		buffer.Write(value)
+	}
+	buffer.WriteString("}")
+
+	return buffer.String(), nil
+}
+
+// encodeCSVValue converts a value to the CSV cell. Values which are not
+// scalars are encoded as JSON.
+func encodeCSVValue(value interface{}) (string, error) {
+	switch converted := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return converted, nil
+	case bool:
+		return strconv.FormatBool(converted), nil
+	case int, int64, uint, uint64, float64:
+		return fmt.Sprint(converted), nil
+	}
+
+	encoded, err := json.Marshal(value)
+	return string(encoded), err
+}
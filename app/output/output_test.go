@@ -0,0 +1,106 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriters(t *testing.T) {
+	records := []*Record{
+		{
+			Text: "!1     ls -la",
+			Fields: []Field{
+				{Name: "number", Value: 1},
+				{Name: "command", Value: "ls -la"},
+				{Name: "duration", Value: nil},
+				{Name: "trace", Value: true},
+			},
+		},
+		{
+			Text: "!2     echo \"a,b\"\n",
+			Fields: []Field{
+				{Name: "number", Value: 2},
+				{Name: "command", Value: `echo "a,b"`},
+				{Name: "duration", Value: int64(3)},
+				{Name: "trace", Value: false},
+			},
+		},
+		{
+			Fields: []Field{
+				{Name: "number", Value: 3},
+				{Name: "command", Value: "make"},
+				{Name: "duration", Value: []int{1, 2}},
+				{Name: "trace", Value: false},
+			},
+		},
+	}
+	cases := []struct {
+		format   string
+		records  []*Record
+		expected string
+	}{
+		{
+			format:   FormatText,
+			records:  records,
+			expected: "!1     ls -la\n!2     echo \"a,b\"\n",
+		},
+		{
+			format:  FormatJSON,
+			records: records,
+			expected: "[\n" +
+				`{"number":1,"command":"ls -la","duration":null,"trace":true},` + "\n" +
+				`{"number":2,"command":"echo \"a,b\"","duration":3,"trace":false},` + "\n" +
+				`{"number":3,"command":"make","duration":[1,2],"trace":false}` + "\n]\n",
+		},
+		{
+			format:   FormatJSON,
+			expected: "[]\n",
+		},
+		{
+			format:  FormatNDJSON,
+			records: records,
+			expected: `{"number":1,"command":"ls -la","duration":null,"trace":true}` + "\n" +
+				`{"number":2,"command":"echo \"a,b\"","duration":3,"trace":false}` + "\n" +
+				`{"number":3,"command":"make","duration":[1,2],"trace":false}` + "\n",
+		},
+		{
+			format:  FormatCSV,
+			records: records,
+			expected: "number,command,duration,trace\n" +
+				"1,ls -la,,true\n" +
+				"2,\"echo \"\"a,b\"\"\",3,false\n" +
+				"3,make,\"[1,2]\",false\n",
+		},
+	}
+
+	for _, testCase := range cases {
+		buffer := new(bytes.Buffer)
+		writer, err := NewWriter(testCase.format, buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, record := range testCase.records {
+			if err = writer.Write(record); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if buffer.String() != testCase.expected {
+			t.Errorf("%s: got %q, expected %q", testCase.format, buffer.String(), testCase.expected)
+		}
+	}
+}
+
+func TestNewWriterUnknownFormat(t *testing.T) {
+	if _, err := NewWriter("xml", new(bytes.Buffer)); err == nil {
+		t.Error("Unknown format is accepted")
+	}
+	for _, format := range Formats {
+		if !IsSupported(format) {
+			t.Errorf("Format %s is not supported", format)
+		}
+	}
+}
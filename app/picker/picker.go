@@ -0,0 +1,356 @@
+package picker
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"unicode"
+	"unicode/utf8"
+
+	term "github.com/docker/docker/pkg/term"
+
+	"github.com/9seconds/ah/app/utils"
+)
+
+// Action is what user wants to do with the picked item.
+type Action uint8
+
+// Actions of the picker.
+const (
+	ActionCancel Action = iota
+	ActionPrint
+	ActionExecute
+	ActionTrace
+	ActionBookmark
+)
+
+// TerminalDevice is a terminal picker works with. Picker does not use
+// standard streams so its result may be captured by shell.
+const TerminalDevice = "/dev/tty"
+
+// minPreviewHeight is a minimal height of the screen when preview pane is
+// shown.
+const minPreviewHeight = 12
+
+const helpLine = "enter:print  ^E:execute  ^T:trace  ^B:bookmark  esc:cancel"
+
+var escapeSequenceRegexp = utils.CreateRegexp(`\x1b(\[[0-9;?]*[ -/]*[@-~]|\][^\x07\x1b]*(\x07|\x1b\\)?|.)`)
+
+// Item is an entry of the picker.
+type Item struct {
+	// Text is a line shown in the list.
+	Text string
+	// Search is a text incremental search is performed on.
+	Search string
+	// Preview returns lines of the preview pane. It may be nil.
+	Preview func(height int) []string
+}
+
+// preview is a cached content of the preview pane.
+type preview struct {
+	lines  []string
+	height int
+}
+
+type picker struct {
+	items    []Item
+	query    []rune
+	matches  []int
+	selected int
+	top      int
+	previews map[int]preview
+	tty      *os.File
+	width    int
+	height   int
+}
+
+// Pick shows full-screen picker on the terminal. It returns an index of the
+// picked item and an action to do with it. Index is -1 if picking is
+// cancelled.
+func Pick(items []Item, query string) (int, Action, error) {
+	tty, err := os.OpenFile(TerminalDevice, os.O_RDWR, 0)
+	if err != nil {
+		return -1, ActionCancel, fmt.Errorf("Cannot open terminal: %v", err)
+	}
+	defer tty.Close()
+
+	var state *term.State
+	control(tty, func(fd uintptr) {
+		state, err = term.SetRawTerminal(fd)
+	})
+	if err != nil {
+		return -1, ActionCancel, fmt.Errorf("Cannot set terminal to raw mode: %v", err)
+	}
+	defer control(tty, func(fd uintptr) {
+		term.RestoreTerminal(fd, state)
+	})
+
+	// alternate screen keeps the content of the terminal intact.
+	tty.WriteString("\x1b[?1049h")
+	defer tty.WriteString("\x1b[?1049l")
+
+	pc := &picker{
+		items:    items,
+		query:    []rune(query),
+		previews: make(map[int]preview),
+		tty:      tty,
+	}
+	pc.search()
+
+	return pc.run()
+}
+
+func (pc *picker) run() (int, Action, error) {
+	keys := make(chan []byte)
+	readErrors := make(chan error, 1)
+	go func() {
+		for {
+			buffer := make([]byte, 256)
+			read, err := pc.tty.Read(buffer)
+			if err != nil {
+				readErrors <- err
+				return
+			}
+			keys <- buffer[:read]
+		}
+	}()
+
+	resizes := make(chan os.Signal, 1)
+	signal.Notify(resizes, syscall.SIGWINCH)
+	defer signal.Stop(resizes)
+
+	for {
+		pc.render()
+
+		select {
+		case err := <-readErrors:
+			return -1, ActionCancel, err
+		case <-resizes:
+		case key := <-keys:
+			if action, done := pc.handleKey(key); done {
+				if action == ActionCancel || len(pc.matches) == 0 {
+					return -1, ActionCancel, nil
+				}
+				return pc.matches[pc.selected], action, nil
+			}
+		}
+	}
+}
+
+// handleKey processes the input. It returns true if picking is finished.
+func (pc *picker) handleKey(key []byte) (Action, bool) {
+	switch string(key) {
+	case "\r", "\n":
+		return ActionPrint, true
+	case "\x05":
+		return ActionExecute, true
+	case "\x14":
+		return ActionTrace, true
+	case "\x02":
+		return ActionBookmark, true
+	case "\x1b", "\x03", "\x07":
+		return ActionCancel, true
+	case "\x1b[A", "\x1bOA", "\x10":
+		pc.move(-1)
+	case "\x1b[B", "\x1bOB", "\x0e":
+		pc.move(1)
+	case "\x1b[5~":
+		pc.move(-pc.listHeight())
+	case "\x1b[6~":
+		pc.move(pc.listHeight())
+	case "\x7f", "\x08":
+		if len(pc.query) > 0 {
+			pc.query = pc.query[:len(pc.query)-1]
+			pc.search()
+		}
+	case "\x15":
+		pc.query = pc.query[:0]
+		pc.search()
+	case "\x17":
+		query := strings.TrimRightFunc(string(pc.query), unicode.IsSpace)
+		pc.query = []rune(query[:strings.LastIndexFunc(query, unicode.IsSpace)+1])
+		pc.search()
+	default:
+		if key[0] == '\x1b' {
+			break
+		}
+		changed := false
+		for len(key) > 0 {
+			character, size := utf8.DecodeRune(key)
+			key = key[size:]
+			if unicode.IsPrint(character) {
+				pc.query = append(pc.query, character)
+				changed = true
+			}
+		}
+		if changed {
+			pc.search()
+		}
+	}
+
+	return ActionCancel, false
+}
+
+// search filters items by the query. Items have to contain all words of
+// the query. Search is case insensitive unless query has uppercase letters.
+func (pc *picker) search() {
+	query := string(pc.query)
+	ignoreCase := strings.ToLower(query) == query
+	words := strings.Fields(query)
+
+	pc.matches = pc.matches[:0]
+	for idx, item := range pc.items {
+		text := item.Search
+		if ignoreCase {
+			text = strings.ToLower(text)
+		}
+		matched := true
+		for _, word := range words {
+			if !strings.Contains(text, word) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			pc.matches = append(pc.matches, idx)
+		}
+	}
+
+	pc.selected = 0
+	pc.top = 0
+}
+
+func (pc *picker) move(delta int) {
+	pc.selected += delta
+	if pc.selected >= len(pc.matches) {
+		pc.selected = len(pc.matches) - 1
+	}
+	if pc.selected < 0 {
+		pc.selected = 0
+	}
+}
+
+func (pc *picker) listHeight() int {
+	height := pc.height - 2
+	if pc.height >= minPreviewHeight {
+		height = (pc.height - 3) / 2
+	}
+	if height < 1 {
+		height = 1
+	}
+	return height
+}
+
+func (pc *picker) render() {
+	pc.width, pc.height = 80, 24
+	control(pc.tty, func(fd uintptr) {
+		if winsize, err := term.GetWinsize(fd); err == nil && winsize.Width > 0 && winsize.Height > 0 {
+			pc.width, pc.height = int(winsize.Width), int(winsize.Height)
+		}
+	})
+
+	listHeight := pc.listHeight()
+	if pc.selected < pc.top {
+		pc.top = pc.selected
+	} else if pc.selected >= pc.top+listHeight {
+		pc.top = pc.selected - listHeight + 1
+	}
+
+	rows := []string{
+		"> " + string(pc.query),
+		fmt.Sprintf("  %d/%d  %s", len(pc.matches), len(pc.items), helpLine),
+	}
+	highlighted := -1
+	for row := 0; row < listHeight; row++ {
+		idx := pc.top + row
+		if idx >= len(pc.matches) {
+			rows = append(rows, "")
+			continue
+		}
+		if idx == pc.selected {
+			highlighted = len(rows)
+		}
+		rows = append(rows, pc.items[pc.matches[idx]].Text)
+	}
+	if pc.height >= minPreviewHeight {
+		rows = append(rows, strings.Repeat("-", pc.width))
+		rows = append(rows, pc.preview(pc.height-len(rows))...)
+	}
+
+	screen := new(bytes.Buffer)
+	screen.WriteString("\x1b[?25l")
+	for row := 0; row < pc.height; row++ {
+		// rows are positioned explicitly so the screen is never scrolled.
+		fmt.Fprintf(screen, "\x1b[%d;1H", row+1)
+		if row == highlighted {
+			screen.WriteString("\x1b[7m")
+		}
+		if row < len(rows) {
+			screen.WriteString(SanitizeLine(rows[row], pc.width))
+		}
+		screen.WriteString("\x1b[K\x1b[0m")
+	}
+	fmt.Fprintf(screen, "\x1b[1;%dH\x1b[?25h", utf8.RuneCountInString(rows[0])+1)
+
+	pc.tty.Write(screen.Bytes())
+}
+
+// preview returns the preview of the selected item. Previews are cached
+// because they may be expensive to produce.
+func (pc *picker) preview(height int) []string {
+	if len(pc.matches) == 0 || height <= 0 {
+		return nil
+	}
+	idx := pc.matches[pc.selected]
+	if pc.items[idx].Preview == nil {
+		return nil
+	}
+
+	cached, ok := pc.previews[idx]
+	if !ok || cached.height < height {
+		cached = preview{lines: pc.items[idx].Preview(height), height: height}
+		pc.previews[idx] = cached
+	}
+	if len(cached.lines) > height {
+		return cached.lines[:height]
+	}
+	return cached.lines
+}
+
+// control calls the function with the descriptor of the terminal. Fd
+// method is not used because it switches the file to blocking mode and
+// reading goroutine cannot be stopped by closing the file then.
+func control(tty *os.File, function func(fd uintptr)) {
+	if conn, err := tty.SyscallConn(); err == nil {
+		conn.Control(function)
+	}
+}
+
+// SanitizeLine prepares the line to be shown in the terminal: escape
+// sequences and control characters are removed, tabs are expanded and the
+// line is cut to the given width.
+func SanitizeLine(line string, width int) string {
+	line = escapeSequenceRegexp.ReplaceAll(line, "")
+
+	sanitized := make([]rune, 0, width)
+	for _, character := range line {
+		switch {
+		case len(sanitized) >= width:
+			return string(sanitized)
+		case character == '\t':
+			sanitized = append(sanitized, ' ')
+			for len(sanitized) < width && len(sanitized)%8 != 0 {
+				sanitized = append(sanitized, ' ')
+			}
+		case character == '\n':
+			sanitized = append(sanitized, ' ')
+		case unicode.IsPrint(character):
+			sanitized = append(sanitized, character)
+		}
+	}
+
+	return string(sanitized)
+}
@@ -0,0 +1,501 @@
+package traces
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Version is the version of the trace format written by ah. It has to be
+	// incremented on any incompatible change of the format.
+	Version = 4
+
+	// chunkedVersion is the first version which stores the output as
+	// chunks tagged with their streams.
+	chunkedVersion = 2
+
+	// timedVersion is the first version which stores the time of each
+	// chunk.
+	timedVersion = 3
+
+	// legacyChunkSize is the size of the chunks legacy traces are read with.
+	legacyChunkSize = 32 * 1024
+
+	// magic starts the first line of the trace, the line ends with the
+	// version of the format. Legacy traces are bare gzip streams so they
+	// start with gzip magic bytes.
+	magic = "AHTRACE "
+
+	// partialPrefix starts names of the traces which are being saved.
+	// Such files are hidden so listings of the traces skip them.
+	partialPrefix = ".ah"
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// Stream is a stream of the command output.
+type Stream uint8
+
+// Streams of the output.
+const (
+	// StreamOutput is a mixed output of legacy traces and commands executed
+	// with pseudo TTY.
+	StreamOutput Stream = iota
+	StreamStdout
+	StreamStderr
+	// StreamResize is not an output but a window size of the pseudo TTY
+	// set when command was started or the terminal was resized.
+	StreamResize
+)
+
+var streamNames = []string{"output", "stdout", "stderr", "resize"}
+
+// Chunk is a piece of the output written into one stream.
+type Chunk struct {
+	Stream Stream
+	Data   []byte
+	// Offset is the time since the start of the command when the chunk was
+	// written. It is 0 for traces without timings.
+	Offset time.Duration
+}
+
+// NewResizeChunk creates the chunk of the resize stream with the window
+// size.
+func NewResizeChunk(width uint16, height uint16, offset time.Duration) *Chunk {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint16(data, width)
+	binary.BigEndian.PutUint16(data[2:], height)
+
+	return &Chunk{Stream: StreamResize, Data: data, Offset: offset}
+}
+
+// WindowSize returns the width and the height of the window stored in the
+// resize chunk.
+func (c *Chunk) WindowSize() (width uint16, height uint16, ok bool) {
+	if c.Stream != StreamResize || len(c.Data) != 4 {
+		return
+	}
+	return binary.BigEndian.Uint16(c.Data), binary.BigEndian.Uint16(c.Data[2:]), true
+}
+
+// Header is a metadata of the trace.
+//
+// Trace file is the line with magic and version, the header encoded as
+// JSON in a single line and the output of the command compressed with
+// gzip:
+//
+//	AHTRACE 4
+//	{"version":4,"command":"make",...}
+//	<gzip stream>
+//
+// Since version 2 output is a sequence of chunks in the order they were
+// written. Each chunk is a byte with the stream, uvarint length of the
+// data and the data itself. Since version 3 the stream byte is followed by
+// uvarint number of microseconds since the previous chunk (or since the
+// start of the command for the first one). Since version 4 window sizes of
+// the pseudo TTY are stored as chunks of the resize stream with the width
+// and the height as big endian uint16. Version 1 stores the mixed output as
+// is.
+// Legacy traces have no magic line and header, they are gzip streams only.
+type Header struct {
+	Version     int       `json:"version"`
+	Command     string    `json:"command"`
+	ExitStatus  int       `json:"exit_status"`
+	Signal      string    `json:"signal,omitempty"`
+	Started     time.Time `json:"started"`
+	Finished    time.Time `json:"finished"`
+	Shell       string    `json:"shell"`
+	Interactive bool      `json:"interactive"`
+	PseudoTTY   bool      `json:"pseudo_tty"`
+	Host        string    `json:"host"`
+	Size        int64     `json:"size"`
+	Streams     []string  `json:"streams,omitempty"`
+}
+
+// GetDuration returns how long the command was executed.
+func (h *Header) GetDuration() time.Duration {
+	return h.Finished.Sub(h.Started)
+}
+
+func (s Stream) String() string {
+	if int(s) < len(streamNames) {
+		return streamNames[s]
+	}
+	return fmt.Sprintf("stream%d", s)
+}
+
+// Trace is an opened trace. It reads the decompressed output of the
+// command.
+type Trace struct {
+	// Header is the metadata of the trace. It is nil for legacy traces.
+	Header *Header
+
+	file    *os.File
+	output  *gzip.Reader
+	chunks  *bufio.Reader
+	timed   bool
+	offset  time.Duration
+	pending []byte
+}
+
+// Open opens the trace and reads its header.
+func Open(filename string) (*Trace, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(file)
+	header, err := readHeader(reader)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("Cannot read trace %s: %v", filename, err)
+	}
+
+	output, err := gzip.NewReader(reader)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("Cannot read trace %s: %v", filename, err)
+	}
+
+	trace := &Trace{Header: header, file: file, output: output}
+	if header != nil && header.Version >= chunkedVersion {
+		trace.chunks = bufio.NewReader(output)
+		trace.timed = header.Version >= timedVersion
+	}
+
+	return trace, nil
+}
+
+// ReadHeader reads the header of the trace only. It returns nil header for
+// legacy traces.
+func ReadHeader(filename string) (*Header, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return readHeader(bufio.NewReader(file))
+}
+
+// IsSeparated tells if stdout and stderr are stored separately.
+func (t *Trace) IsSeparated() bool {
+	return t.chunks != nil && !t.Header.PseudoTTY
+}
+
+// HasTimings tells if times of the chunks are stored.
+func (t *Trace) HasTimings() bool {
+	return t.timed
+}
+
+// ReadChunk returns the next chunk of the output. Output of the traces
+// without chunks is returned in chunks of StreamOutput.
+func (t *Trace) ReadChunk() (*Chunk, error) {
+	if t.chunks == nil {
+		data := make([]byte, legacyChunkSize)
+		read, err := t.output.Read(data)
+		if read > 0 {
+			return &Chunk{Stream: StreamOutput, Data: data[:read]}, nil
+		}
+		return nil, err
+	}
+
+	stream, err := t.chunks.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if t.timed {
+		delta, err := binary.ReadUvarint(t.chunks)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		t.offset += time.Duration(delta) * time.Microsecond
+	}
+	length, err := binary.ReadUvarint(t.chunks)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	data := make([]byte, length)
+	if _, err = io.ReadFull(t.chunks, data); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	return &Chunk{Stream: Stream(stream), Data: data, Offset: t.offset}, nil
+}
+
+// Read reads the output of all streams in the order they were written.
+func (t *Trace) Read(buffer []byte) (int, error) {
+	for len(t.pending) == 0 {
+		chunk, err := t.ReadChunk()
+		if err != nil {
+			return 0, err
+		}
+		if chunk.Stream != StreamResize {
+			t.pending = chunk.Data
+		}
+	}
+
+	read := copy(buffer, t.pending)
+	t.pending = t.pending[read:]
+
+	return read, nil
+}
+
+// Close closes the trace file.
+func (t *Trace) Close() error {
+	t.output.Close()
+	return t.file.Close()
+}
+
+func readHeader(reader *bufio.Reader) (*Header, error) {
+	start, err := reader.Peek(len(gzipMagic))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(start, gzipMagic) {
+		return nil, nil
+	}
+
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, magic) {
+		return nil, errors.New("Unknown trace format")
+	}
+	version, err := strconv.Atoi(strings.TrimSpace(line[len(magic):]))
+	if err != nil {
+		return nil, fmt.Errorf("Incorrect version of the trace: %v", err)
+	}
+	if version > Version {
+		return nil, fmt.Errorf("Version %d of the trace is not supported, please upgrade ah", version)
+	}
+
+	encoded, err := reader.ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	header := new(Header)
+	if err = json.Unmarshal(encoded, header); err != nil {
+		return nil, fmt.Errorf("Cannot parse header of the trace: %v", err)
+	}
+
+	return header, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Writer compresses the output of the command into the temporary file.
+// Metadata is known only when command is finished so the trace is
+// assembled on Save. Writer may be used from several goroutines.
+type Writer struct {
+	body     *os.File
+	buffered *bufio.Writer
+	output   *gzip.Writer
+	size     int64
+	streams  []Stream
+	started  time.Time
+	offset   time.Duration
+	finished bool
+	lock     sync.Mutex
+}
+
+type streamWriter struct {
+	writer *Writer
+	stream Stream
+}
+
+// NewWriter creates a writer with the temporary file in the given
+// directory. Times of the chunks are counted from started.
+func NewWriter(tmpDir string, started time.Time) (*Writer, error) {
+	body, err := ioutil.TempFile(tmpDir, "ah")
+	if err != nil {
+		return nil, err
+	}
+
+	buffered := bufio.NewWriter(body)
+	return &Writer{body: body, buffered: buffered, output: gzip.NewWriter(buffered), started: started}, nil
+}
+
+// Stream returns a writer of the output into the given stream.
+func (w *Writer) Stream(stream Stream) io.Writer {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.streams = append(w.streams, stream)
+	return &streamWriter{writer: w, stream: stream}
+}
+
+func (w *Writer) writeChunk(stream Stream, content []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if len(content) == 0 {
+		return 0, nil
+	}
+
+	return w.writeChunkAt(stream, content, time.Since(w.started))
+}
+
+func (w *Writer) writeChunkAt(stream Stream, content []byte, offset time.Duration) (int, error) {
+	if offset < w.offset {
+		offset = w.offset
+	}
+	delta := offset/time.Microsecond - w.offset/time.Microsecond
+	w.offset = offset
+
+	prefix := make([]byte, 1+2*binary.MaxVarintLen64)
+	prefix[0] = byte(stream)
+	length := 1
+	length += binary.PutUvarint(prefix[length:], uint64(delta))
+	length += binary.PutUvarint(prefix[length:], uint64(len(content)))
+	if _, err := w.output.Write(prefix[:length]); err != nil {
+		return 0, err
+	}
+	written, err := w.output.Write(content)
+	if stream != StreamResize {
+		w.size += int64(written)
+	}
+
+	return written, err
+}
+
+// Resize stores the window size of the pseudo TTY.
+func (w *Writer) Resize(width uint16, height uint16) error {
+	_, err := w.writeChunk(StreamResize, NewResizeChunk(width, height, 0).Data)
+	return err
+}
+
+// WriteChunk stores the chunk with its own offset instead of the time
+// passed since start. It is used to convert outputs recorded elsewhere.
+// Offsets of the chunks should not decrease.
+func (w *Writer) WriteChunk(chunk *Chunk) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if chunk.Stream != StreamResize && !w.hasStream(chunk.Stream) {
+		w.streams = append(w.streams, chunk.Stream)
+	}
+	if len(chunk.Data) == 0 {
+		return nil
+	}
+	_, err := w.writeChunkAt(chunk.Stream, chunk.Data, chunk.Offset)
+
+	return err
+}
+
+func (w *Writer) hasStream(stream Stream) bool {
+	for _, known := range w.streams {
+		if known == stream {
+			return true
+		}
+	}
+	return false
+}
+
+func (sw *streamWriter) Write(content []byte) (int, error) {
+	return sw.writer.writeChunk(sw.stream, content)
+}
+
+// Finish finishes compression of the output. Output written after that is
+// lost.
+func (w *Writer) Finish() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.finish()
+}
+
+func (w *Writer) finish() error {
+	if w.finished {
+		return nil
+	}
+	w.finished = true
+
+	if err := w.output.Close(); err != nil {
+		return err
+	}
+	return w.buffered.Flush()
+}
+
+// Save finishes compression and writes the trace with the header into the
+// file. Size in the header is set to the number of bytes written. Trace is
+// written into the hidden temporary file in the same directory and renamed
+// so readers never see incomplete traces.
+func (w *Writer) Save(filename string, header *Header) (err error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if err = w.finish(); err != nil {
+		return
+	}
+	if _, err = w.body.Seek(0, os.SEEK_SET); err != nil {
+		return
+	}
+
+	header.Version = Version
+	header.Size = w.size
+	header.Streams = make([]string, len(w.streams))
+	for idx, stream := range w.streams {
+		header.Streams[idx] = stream.String()
+	}
+	encoded, err := json.Marshal(header)
+	if err != nil {
+		return
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(filename), partialPrefix)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(file.Name())
+		}
+	}()
+
+	buffered := bufio.NewWriter(file)
+	fmt.Fprintf(buffered, "%s%d\n%s\n", magic, Version, encoded)
+	if _, err = io.Copy(buffered, w.body); err == nil {
+		err = buffered.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), filename)
+	}
+
+	return
+}
+
+// Name returns the name of the temporary file with the compressed output.
+// It may be used to rescue the output if trace cannot be saved.
+func (w *Writer) Name() string {
+	return w.body.Name()
+}
+
+// Close closes and removes the temporary file.
+func (w *Writer) Close() error {
+	w.body.Close()
+	return os.Remove(w.body.Name())
+}
@@ -0,0 +1,239 @@
+package traces
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func makeDirs(t *testing.T) (tmpDir string, tracesDir string) {
+	tmpDir, err := ioutil.TempDir("", "ah")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracesDir = filepath.Join(tmpDir, "traces")
+	if err = os.Mkdir(tracesDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func readChunks(t *testing.T, filename string) (*Trace, []Chunk) {
+	trace, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer trace.Close()
+
+	var chunks []Chunk
+	for {
+		chunk, err := trace.ReadChunk()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks = append(chunks, *chunk)
+	}
+	return trace, chunks
+}
+
+func TestWriterSave(t *testing.T) {
+	tmpDir, tracesDir := makeDirs(t)
+	defer os.RemoveAll(tmpDir)
+
+	writer, err := NewWriter(tmpDir, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	chunks := []Chunk{
+		*NewResizeChunk(80, 24, 0),
+		{Stream: StreamStdout, Data: []byte("out\n"), Offset: time.Second},
+		{Stream: StreamStderr, Data: []byte("err\n"), Offset: 1500 * time.Millisecond},
+		*NewResizeChunk(120, 40, 2*time.Second),
+		{Stream: StreamStdout, Data: []byte("done\n"), Offset: 3 * time.Second},
+	}
+	for idx := range chunks {
+		if err = writer.WriteChunk(&chunks[idx]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filename := filepath.Join(tracesDir, "trace")
+	if err = writer.Save(filename, &Header{Command: "make", ExitStatus: 2, Signal: "SIGINT"}); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := ioutil.ReadDir(tracesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name() != "trace" {
+		t.Errorf("Traces directory has to contain the trace only, got %d files", len(files))
+	}
+
+	header, err := ReadHeader(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Header{Version: Version, Command: "make", ExitStatus: 2, Signal: "SIGINT",
+		Size: 13, Streams: []string{"stdout", "stderr"}}
+	if !reflect.DeepEqual(header, expected) {
+		t.Errorf("Header is %+v, expected %+v", header, expected)
+	}
+
+	trace, actual := readChunks(t, filename)
+	if !trace.IsSeparated() || !trace.HasTimings() {
+		t.Errorf("Trace has to be separated and timed")
+	}
+	if !reflect.DeepEqual(actual, chunks) {
+		t.Errorf("Chunks are %v, expected %v", actual, chunks)
+	}
+	if width, height, ok := actual[3].WindowSize(); !ok || width != 120 || height != 40 {
+		t.Errorf("Window size is %dx%d (%t), expected 120x40", width, height, ok)
+	}
+	if _, _, ok := actual[1].WindowSize(); ok {
+		t.Errorf("Output chunk cannot have a window size")
+	}
+
+	trace, err = Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer trace.Close()
+	output, err := ioutil.ReadAll(trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(output) != "out\nerr\ndone\n" {
+		t.Errorf("Output is %q", output)
+	}
+}
+
+func TestWriterStreams(t *testing.T) {
+	tmpDir, tracesDir := makeDirs(t)
+	defer os.RemoveAll(tmpDir)
+
+	writer, err := NewWriter(tmpDir, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	stdout := writer.Stream(StreamStdout)
+	stderr := writer.Stream(StreamStderr)
+	io.WriteString(stdout, "a")
+	io.WriteString(stderr, "")
+	io.WriteString(stderr, "b")
+	io.WriteString(stdout, "c")
+
+	filename := filepath.Join(tracesDir, "trace")
+	if err = writer.Save(filename, new(Header)); err != nil {
+		t.Fatal(err)
+	}
+
+	_, chunks := readChunks(t, filename)
+	var streams []Stream
+	var output []byte
+	for _, chunk := range chunks {
+		streams = append(streams, chunk.Stream)
+		output = append(output, chunk.Data...)
+	}
+	if !reflect.DeepEqual(streams, []Stream{StreamStdout, StreamStderr, StreamStdout}) || string(output) != "abc" {
+		t.Errorf("Chunks are %v", chunks)
+	}
+}
+
+func TestOpenLegacy(t *testing.T) {
+	tmpDir, _ := makeDirs(t)
+	defer os.RemoveAll(tmpDir)
+
+	buffer := new(bytes.Buffer)
+	compressed := gzip.NewWriter(buffer)
+	io.WriteString(compressed, "legacy output")
+	compressed.Close()
+
+	filename := filepath.Join(tmpDir, "legacy")
+	if err := ioutil.WriteFile(filename, buffer.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if header, err := ReadHeader(filename); header != nil || err != nil {
+		t.Errorf("Legacy trace has header %v, error %v", header, err)
+	}
+
+	trace, chunks := readChunks(t, filename)
+	if trace.Header != nil || trace.IsSeparated() || trace.HasTimings() {
+		t.Errorf("Legacy trace has to have no header, streams and timings")
+	}
+	expected := []Chunk{{Stream: StreamOutput, Data: []byte("legacy output")}}
+	if !reflect.DeepEqual(chunks, expected) {
+		t.Errorf("Chunks are %v, expected %v", chunks, expected)
+	}
+}
+
+func TestReadHeaderErrors(t *testing.T) {
+	tmpDir, _ := makeDirs(t)
+	defer os.RemoveAll(tmpDir)
+
+	cases := map[string]string{
+		"unknown":     "something else\n",
+		"version":     "AHTRACE x\n{}\n",
+		"unsupported": "AHTRACE 100\n{}\n",
+		"header":      "AHTRACE 4\n{\n",
+	}
+
+	for name, content := range cases {
+		filename := filepath.Join(tmpDir, name)
+		if err := ioutil.WriteFile(filename, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadHeader(filename); err == nil {
+			t.Errorf("%s: header has to be rejected", name)
+		}
+		if _, err := Open(filename); err == nil {
+			t.Errorf("%s: trace has to be rejected", name)
+		}
+	}
+}
+
+func TestWriterSaveFailed(t *testing.T) {
+	tmpDir, tracesDir := makeDirs(t)
+	defer os.RemoveAll(tmpDir)
+
+	writer, err := NewWriter(tmpDir, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	io.WriteString(writer.Stream(StreamStdout), "output")
+
+	// trace cannot replace the directory which is not empty.
+	filename := filepath.Join(tracesDir, "trace")
+	if err = os.MkdirAll(filepath.Join(filename, "busy"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Save(filename, new(Header)); err == nil {
+		t.Fatal("Trace cannot be saved over the directory")
+	}
+
+	files, err := ioutil.ReadDir(tracesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name() != "trace" {
+		t.Errorf("Partial trace has to be removed, got %d files", len(files))
+	}
+	if _, err = os.Stat(writer.Name()); err != nil {
+		t.Errorf("Output has to be kept in the temporary file: %v", err)
+	}
+}
@@ -11,14 +11,20 @@ import (
 	pty "github.com/kr/pty"
 )
 
+// Resized is called with the window size of the pseudo TTY when command is
+// started and each time the terminal is resized.
+type Resized func(width uint16, height uint16)
+
 // Exec runs a command with connected streams and according to the TTY usage.
-func Exec(cmd string, shell string, interactive bool, pseudoTTY bool, stdin io.Reader, stdout io.Writer, stderr io.Writer) *exec.ExitError {
+// resized may be nil.
+func Exec(cmd string, shell string, interactive bool, pseudoTTY bool, stdin io.Reader, stdout io.Writer, stderr io.Writer,
+	resized Resized) *exec.ExitError {
 	command := getCommand(cmd, interactive, shell)
 	attachSignalsToProcess(command)
 
 	var err error
 	if pseudoTTY {
-		err = runTtyCommand(command, stdin, stdout, stderr)
+		err = runTtyCommand(command, stdin, stdout, stderr, resized)
 	} else {
 		err = runStdCommand(command, stdin, stdout, stderr)
 	}
@@ -45,7 +51,7 @@ func runStdCommand(command *exec.Cmd, stdin io.Reader, stdout io.Writer, stderr
 	return command.Wait()
 }
 
-func runTtyCommand(command *exec.Cmd, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+func runTtyCommand(command *exec.Cmd, stdin io.Reader, stdout io.Writer, stderr io.Writer, resized Resized) error {
 	pty, err := pty.Start(command)
 	if err != nil {
 		return err
@@ -59,7 +65,7 @@ func runTtyCommand(command *exec.Cmd, stdin io.Reader, stdout io.Writer, stderr
 	}
 	defer term.RestoreTerminal(hostFd, oldTerminalState)
 
-	monitorTtyResize(hostFd, pty.Fd())
+	monitorTtyResize(hostFd, pty.Fd(), resized)
 
 	go io.Copy(pty, stdin)
 	go io.Copy(stdout, pty)
@@ -67,25 +73,28 @@ func runTtyCommand(command *exec.Cmd, stdin io.Reader, stdout io.Writer, stderr
 	return command.Wait()
 }
 
-func monitorTtyResize(hostFd uintptr, guestFd uintptr) {
-	resizeTty(hostFd, guestFd)
+func monitorTtyResize(hostFd uintptr, guestFd uintptr, resized Resized) {
+	resizeTty(hostFd, guestFd, resized)
 
 	winchChan := make(chan os.Signal, 1)
 	signal.Notify(winchChan, syscall.SIGWINCH)
 
 	go func() {
 		for _ = range winchChan {
-			resizeTty(hostFd, guestFd)
+			resizeTty(hostFd, guestFd, resized)
 		}
 	}()
 }
 
-func resizeTty(hostFd uintptr, guestFd uintptr) {
+func resizeTty(hostFd uintptr, guestFd uintptr, resized Resized) {
 	winsize, err := term.GetWinsize(hostFd)
 	if err != nil {
 		return
 	}
 	term.SetWinsize(guestFd, winsize)
+	if resized != nil {
+		resized(winsize.Width, winsize.Height)
+	}
 }
 
 func attachSignalsToProcess(command *exec.Cmd) {
@@ -3,8 +3,35 @@ package utils
 import (
 	"fmt"
 	"regexp"
+	"strings"
+	"unicode"
 )
 
+// Matcher checks if strings match some pattern.
+type Matcher interface {
+	Match(string) bool
+}
+
+// CaseMode defines how matchers treat the case of letters.
+type CaseMode uint8
+
+// Modes of the case sensitivity.
+const (
+	CaseSensitive CaseMode = iota
+	CaseInsensitive
+	// CaseSmart is case insensitive unless pattern has uppercase letters.
+	CaseSmart
+)
+
+type literalMatcher struct {
+	pattern    string
+	ignoreCase bool
+}
+
+type invertedMatcher struct {
+	Matcher
+}
+
 // Regexp is just a wrapper to play with different regular expression libraries
 // to get the best one.
 type Regexp struct {
@@ -16,6 +43,11 @@ func (r *Regexp) Match(suspected string) bool {
 	return r.exp.MatchString(suspected)
 }
 
+// ReplaceAll replaces all matches of regular expression in the string.
+func (r *Regexp) ReplaceAll(suspected string, replacement string) string {
+	return r.exp.ReplaceAllString(suspected, replacement)
+}
+
 // Groups returns a slice of a groups of regular expression applied to the
 // string.
 func (r *Regexp) Groups(suspected string) (groups []string, err error) {
@@ -25,7 +57,10 @@ func (r *Regexp) Groups(suspected string) (groups []string, err error) {
 	} else {
 		groups = make([]string, len(indexes)/2-1)
 		for idx := 0; idx < len(groups); idx++ {
-			groups[idx] = suspected[indexes[2*idx+2]:indexes[2*idx+3]]
+			// optional group which does not participate in the match is empty.
+			if indexes[2*idx+2] >= 0 {
+				groups[idx] = suspected[indexes[2*idx+2]:indexes[2*idx+3]]
+			}
 		}
 	}
 	return
@@ -35,3 +70,67 @@ func (r *Regexp) Groups(suspected string) (groups []string, err error) {
 func CreateRegexp(expression string) *Regexp {
 	return &Regexp{exp: regexp.MustCompile(expression)}
 }
+
+// CompileRegexp creates a regexp from the user input returning an error if
+// expression is invalid.
+func CompileRegexp(expression string) (*Regexp, error) {
+	exp, err := regexp.Compile(expression)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot compile regular expression %s: %v", expression, err)
+	}
+	return &Regexp{exp: exp}, nil
+}
+
+// CreateMatcher creates a matcher for the user defined pattern. Pattern is
+// a regular expression unless literal is set. If invert is set, matcher
+// matches strings which do not match the pattern.
+func CreateMatcher(pattern string, literal bool, caseMode CaseMode, invert bool) (matcher Matcher, err error) {
+	ignoreCase := caseMode == CaseInsensitive || (caseMode == CaseSmart && !hasUppercase(pattern, literal))
+
+	switch {
+	case literal && ignoreCase:
+		matcher = &literalMatcher{pattern: strings.ToLower(pattern), ignoreCase: true}
+	case literal:
+		matcher = &literalMatcher{pattern: pattern}
+	case ignoreCase:
+		matcher, err = CompileRegexp("(?i)" + pattern)
+	default:
+		matcher, err = CompileRegexp(pattern)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if invert {
+		matcher = &invertedMatcher{matcher}
+	}
+	return matcher, nil
+}
+
+func (lm *literalMatcher) Match(suspected string) bool {
+	if lm.ignoreCase {
+		suspected = strings.ToLower(suspected)
+	}
+	return strings.Contains(suspected, lm.pattern)
+}
+
+func (im *invertedMatcher) Match(suspected string) bool {
+	return !im.Matcher.Match(suspected)
+}
+
+// hasUppercase checks if pattern has uppercase letters. Escape sequences
+// of regular expressions like \S or \W are not counted.
+func hasUppercase(pattern string, literal bool) bool {
+	escaped := false
+	for _, character := range pattern {
+		switch {
+		case escaped:
+			escaped = false
+		case character == '\\' && !literal:
+			escaped = true
+		case unicode.IsUpper(character):
+			return true
+		}
+	}
+	return false
+}
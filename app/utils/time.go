@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	relativeTimeRegexp = CreateRegexp(`^(\d+[smhdw])+$`)
+	relativeTimeChunk  = CreateRegexp(`(\d+)([smhdw])`)
+
+	relativeTimeUnits = map[string]time.Duration{
+		"s": time.Second,
+		"m": time.Minute,
+		"h": time.Hour,
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	}
+
+	absoluteTimeLayouts = []string{
+		time.RFC3339,
+		"2006-01-02T15:04:05",
+		"2006-01-02T15:04",
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04",
+		"2006-01-02",
+	}
+
+	clockTimeLayouts = []string{"15:04:05", "15:04"}
+)
+
+// ParseTime parses a point of time relatively to now. It understands
+// relative durations (2h, 1d12h, 30m ago), RFC3339 and absolute dates
+// (2016-03-11, 2016-03-11 14:00), time of the day (14:00) and days with
+// optional time (today, yesterday 14:00). Local timezone is used if it is
+// not set explicitly.
+func ParseTime(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+
+	if relative := strings.TrimSpace(strings.TrimSuffix(value, "ago")); relativeTimeRegexp.Match(relative) {
+		return now.Add(-parseRelativeTime(relative)), nil
+	}
+
+	for _, layout := range absoluteTimeLayouts {
+		if parsed, err := time.ParseInLocation(layout, value, now.Location()); err == nil {
+			return parsed, nil
+		}
+	}
+
+	day := now
+	chunks := strings.Fields(value)
+	if len(chunks) > 0 {
+		switch chunks[0] {
+		case "today":
+			chunks = chunks[1:]
+		case "yesterday":
+			day = now.AddDate(0, 0, -1)
+			chunks = chunks[1:]
+		}
+	}
+
+	switch len(chunks) {
+	case 0:
+		if value != "" {
+			return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, now.Location()), nil
+		}
+	case 1:
+		for _, layout := range clockTimeLayouts {
+			if clock, err := time.Parse(layout, chunks[0]); err == nil {
+				return time.Date(day.Year(), day.Month(), day.Day(),
+					clock.Hour(), clock.Minute(), clock.Second(), 0, now.Location()), nil
+			}
+		}
+	}
+
+	return now, fmt.Errorf("Cannot understand time %s", value)
+}
+
+func parseRelativeTime(value string) (duration time.Duration) {
+	for _, groups := range relativeTimeChunk.exp.FindAllStringSubmatch(value, -1) {
+		count, _ := strconv.Atoi(groups[1])
+		duration += time.Duration(count) * relativeTimeUnits[groups[2]]
+	}
+	return
+}
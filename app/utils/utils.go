@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"fmt"
 	"os"
 	"os/exec"
 	"strings"
@@ -49,6 +50,20 @@ func GetStatusCode(err *exec.ExitError) int {
 	return waitStatus.ExitStatus()
 }
 
+// GetSignal returns a name of the signal which has killed the process or
+// empty string if process has exited by itself.
+func GetSignal(err *exec.ExitError) string {
+	if err == nil {
+		return ""
+	}
+
+	waitStatus, ok := err.Sys().(syscall.WaitStatus)
+	if !ok || !waitStatus.Signaled() {
+		return ""
+	}
+	return waitStatus.Signal().String()
+}
+
 // RemoveWithLogging does the same as os.Remove does but logs.
 func RemoveWithLogging(fileName string) error {
 	err := os.Remove(fileName)
@@ -67,3 +82,19 @@ func RemoveWithLogging(fileName string) error {
 
 	return nil
 }
+
+// FormatSize formats a size in bytes into human readable form.
+func FormatSize(size int64) string {
+	units := []string{"B", "KiB", "MiB", "GiB"}
+	value := float64(size)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+
+	if unit == 0 {
+		return fmt.Sprintf("%d %s", size, units[unit])
+	}
+	return fmt.Sprintf("%.1f %s", value, units[unit])
+}
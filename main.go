@@ -3,16 +3,21 @@ package main
 import (
 	"bytes"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	logrus "github.com/Sirupsen/logrus"
 	docopt "github.com/docopt/docopt-go"
 
 	"github.com/9seconds/ah/app/commands"
 	"github.com/9seconds/ah/app/environments"
+	"github.com/9seconds/ah/app/historyentries"
+	"github.com/9seconds/ah/app/output"
 	"github.com/9seconds/ah/app/slices"
+	"github.com/9seconds/ah/app/traces"
 	"github.com/9seconds/ah/app/utils"
 )
 
@@ -38,13 +43,21 @@ Just a short reminder on possible subcommands:
     - ar - remove commands from the list of auto ah'ed.
     - al - list of commands which should be auto ah'ed.
     - at - creates a command to execute using auto tee if possible.
+    - stats - shows statistics of the history and traces.
+    - grep - searches a pattern in the stored outputs of the commands.
+    - pick - interactive picker of the history commands and bookmarks.
+    - record - stores a directory, exit status and duration of the finished
+      command. It is called by shell hooks.
+    - export - exports an output of the command as asciinema recording.
+    - import - attaches asciinema recording to the command as its output.
 
 Usage:
-    ah [options] s [-z] [-g PATTERN] [<lastNcommands> | <startFromNCommand> <finishByMCommand>]
+    ah [options] s [-z] [-g PATTERN]... [-F] [-i] [--smart-case] [--invert-match] [-q QUERY] [-u] [-A COUNT] [-B COUNT] [-C COUNT] [--template TEMPLATE] [--since TIME] [--until TIME] [--slower-than DURATION] [--faster-than DURATION] [--here | --dir PATH] [--failed] [--durations] [<lastNcommands> | <startFromNCommand> <finishByMCommand>]
     ah [options] b <commandNumber> <bookmarkAs>
     ah [options] e [-x] [-y] <commandNumberOrBookMarkName>
-    ah [options] t [-x] [-y] [--] <command>...
-    ah [options] l <numberOfCommandYouWantToCheck>
+    ah [options] t [-x] [-y] [--stdout | --stderr] [--] <command>...
+    ah [options] l [--stdout | --stderr] [--timestamps | --relative] [--gap DURATION] <numberOfCommandYouWantToCheck>
+    ah [options] l --replay [--speed FACTOR] [--idle-limit DURATION] <numberOfCommandYouWantToCheck>
     ah [options] lb
     ah [options] rb <bookmarkToRemove>...
     ah [options] (gt | gb) (--keepLatest <keepLatest> | --olderThan <olderThan> | --all)
@@ -52,12 +65,18 @@ Usage:
     ah [options] ad [-x] [-y] <command>...
     ah [options] ar <command>...
     ah [options] at <commandToExecute>
+    ah [options] stats [--json] [--top COUNT] [--since TIME] [--until TIME]
+    ah [options] grep [-F] [-i] [--smart-case] [--invert-match] [--since TIME] [--until TIME] <pattern>
+    ah [options] pick [<searchQuery>]
+    ah [options] record [--status STATUS] [--started TIMESTAMP] [--cwd PATH] [--terminal TTY]
+    ah [options] export --asciicast <commandNumber>
+    ah [options] import --asciicast <castFile> <commandNumber>
     ah (-h | --help)
     ah --version
 
 Options:
     -s SHELL, --shell=SHELL
-       Shell flavour you are using.
+       Shell flavour you are using: bash, zsh, fish, ksh, mksh, tcsh or pwsh.
        By default, ah will do some shallow investigations.
     -f HISTFILE, --histfile=HISTFILE
        The path to a history file.
@@ -68,21 +87,108 @@ Options:
        A place where ah has to store its data.
     -m TMPDIR, --tmpdir=TMPDIR
        A temporary place where ah stores an output. Set it only if you need it.
+    --format FORMAT
+       Output format: text, json, ndjson or csv. Text is used by default.
     -g PATTERN, --grep PATTERN
-       A pattern to filter command lines. It is regular expression if no -f option is set.
+       A pattern to filter command lines. It is regular expression if no -F
+       or -z option is set. Several patterns may be given, commands have to
+       match all of them.
+    -F, --fixed-strings
+       Interpret patterns as literal strings, not regular expressions.
+    -i, --ignore-case
+       Ignore case of the letters in patterns.
+    --smart-case
+       Ignore case of the letters unless pattern has uppercase letters.
+    --invert-match
+       Select commands (or lines of the outputs) which do not match patterns.
+    -q QUERY, --query QUERY
+       A query to filter commands, e.g. 'git AND NOT status' or
+       'cmd:/^kubectl/ traced:yes since:3d'. Terms are joined with AND, OR
+       and NOT and may be grouped with parentheses. Fields are cmd, traced,
+       size (e.g. size:>10K), since and until.
     -y, --tty
        Allocates pseudo-tty is necessary.
     -x, --run-in-real-shell
        Runs a command in real interactive shell.
+    --stdout
+       Only standard output of the command: t stores only it and l shows only it.
+    --stderr
+       Only standard error of the command: t stores only it and l shows only it.
+       Without these options l shows both streams, stderr is red in terminal.
+    --timestamps
+       Prefixes lines of the output with the time they were written.
+    --relative
+       Prefixes lines of the output with the time since the start of the command.
+    --gap DURATION
+       Marks pauses in the output longer than DURATION (e.g 10s).
+    --replay
+       Replays the output in the terminal with its original timing. Space
+       pauses, arrows seek, + and - change the speed, q quits.
+    --speed FACTOR
+       Speeds up the replay FACTOR times [default: 1].
+    --idle-limit DURATION
+       Shortens pauses of the replay to DURATION.
+    --asciicast
+       Use asciicast v2 format of asciinema for export and import.
     -z, --fuzzy
-       Interpret -g pattern as fuzzy match string.
+       Interpret -g pattern as fuzzy match string. Commands are ranked by
+       the score of the match, the best match goes last so
+       'ah s -z -g kgp 10' shows top 10 matches. Pattern is case insensitive
+       unless it has uppercase letters.
+    -u, --unique
+       Collapses identical commands into their most recent occurrence and
+       shows how many times they were executed.
+    -A COUNT, --after-context COUNT
+       Shows COUNT commands executed after each matched one.
+    -B COUNT, --before-context COUNT
+       Shows COUNT commands executed before each matched one.
+    -C COUNT, --context COUNT
+       Shows COUNT commands executed before and after each matched one.
+       Groups of commands are separated with '--' lines.
+    --template TEMPLATE
+       Go text/template of the history entry or the name of the template
+       from config, e.g '{{.Number}}\t{{.Time "2006-01-02"}}\t{{.Command}}'.
+    --since TIME
+       Shows only commands executed since TIME. It may be a relative duration
+       (2h, 1d12h, 30m ago), RFC3339 or absolute date (2016-03-11,
+       2016-03-11 14:00), time of the day (14:00) or a day with optional time
+       (today, yesterday 14:00).
+    --until TIME
+       Shows only commands executed before TIME. Format is the same as for
+       --since.
+    --slower-than DURATION
+       Shows only commands which were executed longer than DURATION (e.g 30s or 5m).
+       Makes sense only if durations are known: zsh with EXTENDED_HISTORY
+       stores them, shell hooks record them for other shells.
+    --faster-than DURATION
+       Shows only commands which were executed faster than DURATION.
+    --here
+       Shows only commands executed in the current directory. Works only for
+       commands recorded by shell hooks.
+    --dir PATH
+       Shows only commands executed in the directory PATH.
+    --failed
+       Shows only commands which have failed (exit status is not 0).
+    --durations
+       Shows durations of the commands if shell stores them.
+    --top COUNT
+       A number of entries in the top lists of statistics [default: 10].
+    --json
+       Prints statistics in JSON, the same as --format json.
+    --status STATUS
+       Exit status of the recorded command [default: 0].
+    --started TIMESTAMP
+       Unix timestamp when the recorded command was started.
+    --cwd PATH
+       Directory where the recorded command was executed. Current one is
+       used by default.
+    --terminal TTY
+       Terminal where the recorded command was executed.
     -v, --debug
        Shows a debug log of command execution.`
 
 const version = "ah 0.14.2"
 
-var validateBookmarkName = utils.CreateRegexp(`^[A-Za-z_]\w*$`)
-
 type executor func(map[string]interface{}, *environments.Environment)
 
 func main() {
@@ -136,6 +242,11 @@ func main() {
 		cmdLineEnv.TmpDir = argTmpDir.(string)
 	}
 
+	argFormat := arguments["--format"]
+	if argFormat != nil {
+		cmdLineEnv.Format = argFormat.(string)
+	}
+
 	utils.Logger.WithFields(logrus.Fields{
 		"default":    defaultEnv,
 		"config":     configEnv,
@@ -143,7 +254,14 @@ func main() {
 	}).Debug("Environments")
 
 	env := environments.MergeEnvironments(defaultEnv, configEnv, cmdLineEnv)
+	if argHistFile != nil {
+		// explicit history file overrides the sources from config.
+		env.Histories = nil
+	}
 	utils.Logger.WithField("result env", env).Debug("Ready to start")
+	if !output.IsSupported(env.Format) {
+		utils.Logger.Panicf("Unknown output format %s", env.Format)
+	}
 
 	utils.Logger.WithFields(logrus.Fields{
 		"error": os.MkdirAll(env.TracesDir, 0777),
@@ -151,6 +269,9 @@ func main() {
 	utils.Logger.WithFields(logrus.Fields{
 		"error": os.MkdirAll(env.BookmarksDir, 0777),
 	}).Info("Create bookmarks dir")
+	utils.Logger.WithFields(logrus.Fields{
+		"error": os.MkdirAll(env.IndexDir, 0777),
+	}).Info("Create index dir")
 	utils.Logger.WithFields(logrus.Fields{
 		"error": os.MkdirAll(env.TmpDir, 0777),
 	}).Info("Create create temporary dir")
@@ -167,8 +288,8 @@ func main() {
 		utils.Logger.Info("Execute command 'listTrace'")
 		exec = executeListTrace
 	case arguments["b"].(bool):
-		utils.Logger.Info("Execute command 'listTrace'")
-		exec = executeListTrace
+		utils.Logger.Info("Execute command 'bookmark'")
+		exec = executeBookmark
 	case arguments["e"].(bool):
 		utils.Logger.Info("Execute command 'execute'")
 		exec = executeExec
@@ -193,6 +314,24 @@ func main() {
 	case arguments["at"].(bool):
 		utils.Logger.Info("Execute command 'at'")
 		exec = executeAt
+	case arguments["stats"].(bool):
+		utils.Logger.Info("Execute command 'stats'")
+		exec = executeStats
+	case arguments["grep"].(bool):
+		utils.Logger.Info("Execute command 'grep'")
+		exec = executeGrep
+	case arguments["pick"].(bool):
+		utils.Logger.Info("Execute command 'pick'")
+		exec = executePick
+	case arguments["record"].(bool):
+		utils.Logger.Info("Execute command 'record'")
+		exec = executeRecord
+	case arguments["export"].(bool):
+		utils.Logger.Info("Execute command 'export'")
+		exec = executeExport
+	case arguments["import"].(bool):
+		utils.Logger.Info("Execute command 'import'")
+		exec = executeImport
 	default:
 		utils.Logger.Panic("Unknown command. Please be more precise")
 		return
@@ -206,13 +345,28 @@ func executeTee(arguments map[string]interface{}, env *environments.Environment)
 	tty := arguments["--tty"].(bool)
 	interactive := arguments["--run-in-real-shell"].(bool)
 
+	streams := getStreams(arguments)
+
 	utils.Logger.WithFields(logrus.Fields{
 		"command":     cmd,
 		"pseudo-tty":  tty,
 		"interactive": interactive,
+		"streams":     streams,
 	}).Info("Arguments of 'tee'")
 
-	commands.Tee(cmd, interactive, tty, env)
+	commands.Tee(cmd, interactive, tty, streams, env)
+}
+
+// getStreams returns streams of the output chosen by --stdout and --stderr
+// options. Nil means all streams.
+func getStreams(arguments map[string]interface{}) []traces.Stream {
+	switch {
+	case arguments["--stdout"].(bool):
+		return []traces.Stream{traces.StreamStdout}
+	case arguments["--stderr"].(bool):
+		return []traces.Stream{traces.StreamStderr}
+	}
+	return nil
 }
 
 func executeShow(arguments map[string]interface{}, env *environments.Environment) {
@@ -224,56 +378,268 @@ func executeShow(arguments map[string]interface{}, env *environments.Environment
 		utils.Logger.Panic(err)
 	}
 
-	var filter *utils.Regexp
-	if arguments["--grep"] != nil {
-		query := arguments["--grep"].(string)
-		if arguments["--fuzzy"].(bool) {
-			regex := new(bytes.Buffer)
-			for _, character := range query {
-				regex.WriteString(".*?")
-				regex.WriteString(regexp.QuoteMeta(string(character)))
+	var filter historyentries.Filter
+	var fuzzyPatterns []string
+	caseMode := getCaseMode(arguments)
+	fuzzy := arguments["--fuzzy"].(bool)
+	if fuzzy && arguments["--invert-match"].(bool) {
+		utils.Logger.Panic("Fuzzy matching cannot be inverted")
+	}
+	for _, pattern := range arguments["--grep"].([]string) {
+		if fuzzy {
+			if caseMode == utils.CaseInsensitive {
+				pattern = strings.ToLower(pattern)
 			}
-			regex.WriteString(".*?")
-			query = regex.String()
+			fuzzyPatterns = append(fuzzyPatterns, pattern)
+			pattern = getFuzzyExpression(pattern)
+		}
+		matcher, err := getMatcher(pattern, fuzzy, caseMode, arguments)
+		if err != nil {
+			utils.Logger.Panic(err)
+		}
+		filter = historyentries.JoinFilters(filter, historyentries.MatcherFilter(matcher))
+	}
+	if arguments["--query"] != nil {
+		query, err := historyentries.CompileQuery(arguments["--query"].(string), env)
+		if err != nil {
+			utils.Logger.Panic(err)
+		}
+		filter = historyentries.JoinFilters(filter, query)
+	}
+
+	conditions := getTimeConditions(arguments)
+	conditions = append(conditions, getRecordConditions(arguments, env)...)
+	showDuration := arguments["--durations"].(bool)
+	unique := arguments["--unique"].(bool)
+
+	contextBefore := parseContext(arguments["--context"])
+	contextAfter := contextBefore
+	if arguments["--before-context"] != nil {
+		contextBefore = parseContext(arguments["--before-context"])
+	}
+	if arguments["--after-context"] != nil {
+		contextAfter = parseContext(arguments["--after-context"])
+	}
+	if (contextBefore > 0 || contextAfter > 0) && (unique || len(fuzzyPatterns) > 0) {
+		utils.Logger.Panic("Context cannot be shown for collapsed or ranked commands")
+	}
+
+	templateName := env.Template
+	if arguments["--template"] != nil {
+		templateName = arguments["--template"].(string)
+	}
+	var entryTemplate *historyentries.EntryTemplate
+	if templateName != "" {
+		entryTemplate, err = historyentries.NewEntryTemplate(env.GetTemplate(templateName))
+		if err != nil {
+			utils.Logger.Panic(err)
 		}
-		filter = utils.CreateRegexp(query)
 	}
 
 	utils.Logger.WithFields(logrus.Fields{
-		"slice":  slice,
-		"filter": filter,
+		"slice":        slice,
+		"filter":       filter,
+		"conditions":   len(conditions),
+		"showDuration": showDuration,
+		"unique":       unique,
+		"fuzzy":        fuzzyPatterns,
+		"before":       contextBefore,
+		"after":        contextAfter,
+		"template":     templateName,
 	}).Info("Arguments of 'show'")
 
-	commands.Show(slice, filter, env)
+	commands.Show(slice, filter, conditions, showDuration, unique, fuzzyPatterns,
+		contextBefore, contextAfter, entryTemplate, env)
+}
+
+// parseContext parses a number of context commands. Absent option means
+// no context.
+func parseContext(value interface{}) int {
+	if value == nil {
+		return 0
+	}
+	count, err := strconv.Atoi(value.(string))
+	if err != nil {
+		utils.Logger.Panic(err)
+	} else if count < 0 {
+		utils.Logger.Panic("Number of context commands has to be >= 0")
+	}
+	return count
+}
+
+// getFuzzyExpression returns regular expression which matches strings
+// having all characters of the fuzzy pattern in the same order.
+func getFuzzyExpression(pattern string) string {
+	regex := new(bytes.Buffer)
+	for _, character := range pattern {
+		regex.WriteString(".*?")
+		regex.WriteString(regexp.QuoteMeta(string(character)))
+	}
+	regex.WriteString(".*?")
+
+	return regex.String()
+}
+
+// getCaseMode returns case sensitivity of patterns. Fuzzy patterns are
+// smart-cased by default.
+func getCaseMode(arguments map[string]interface{}) utils.CaseMode {
+	switch {
+	case arguments["--ignore-case"].(bool):
+		return utils.CaseInsensitive
+	case arguments["--smart-case"].(bool) || arguments["--fuzzy"] == true:
+		return utils.CaseSmart
+	}
+	return utils.CaseSensitive
+}
+
+// getMatcher creates a matcher for the pattern according to -F and
+// --invert-match options. Expression is never literal.
+func getMatcher(pattern string, expression bool, caseMode utils.CaseMode,
+	arguments map[string]interface{}) (utils.Matcher, error) {
+	literal := !expression && arguments["--fixed-strings"].(bool)
+	return utils.CreateMatcher(pattern, literal, caseMode, arguments["--invert-match"].(bool))
+}
+
+// getRecordConditions returns conditions for --here, --dir, --failed,
+// --slower-than and --faster-than options. Records are read only if they are required.
+func getRecordConditions(arguments map[string]interface{}, env *environments.Environment) (conditions []historyentries.Condition) {
+	directory := ""
+	if arguments["--dir"] != nil {
+		absolute, err := filepath.Abs(arguments["--dir"].(string))
+		if err != nil {
+			utils.Logger.Panic(err)
+		}
+		directory = absolute
+	} else if arguments["--here"].(bool) {
+		current, err := os.Getwd()
+		if err != nil {
+			utils.Logger.Panic(err)
+		}
+		directory = current
+	}
+	failed := arguments["--failed"].(bool)
+	slowerThan := arguments["--slower-than"]
+	fasterThan := arguments["--faster-than"]
+	if directory == "" && !failed && slowerThan == nil && fasterThan == nil {
+		return
+	}
+
+	records, err := historyentries.LoadRecords(env)
+	if err != nil {
+		utils.Logger.Panic(err)
+	}
+	if directory != "" {
+		conditions = append(conditions, historyentries.InDirectory(directory, records))
+	}
+	if failed {
+		conditions = append(conditions, historyentries.Failed(records))
+	}
+	if slowerThan != nil {
+		conditions = append(conditions, historyentries.SlowerThan(parseDuration(slowerThan.(string)), records))
+	}
+	if fasterThan != nil {
+		conditions = append(conditions, historyentries.FasterThan(parseDuration(fasterThan.(string)), records))
+	}
+
+	return
+}
+
+// getTimeConditions returns conditions for --since and --until options.
+func getTimeConditions(arguments map[string]interface{}) (conditions []historyentries.Condition) {
+	now := time.Unix(environments.CreatedAt, 0)
+	if arguments["--since"] != nil {
+		conditions = append(conditions, historyentries.Since(parseTime(arguments["--since"].(string), now)))
+	}
+	if arguments["--until"] != nil {
+		conditions = append(conditions, historyentries.Until(parseTime(arguments["--until"].(string), now)))
+	}
+	return
+}
+
+func parseTime(value string, now time.Time) time.Time {
+	parsed, err := utils.ParseTime(value, now)
+	if err != nil {
+		utils.Logger.Panic(err)
+	}
+	return parsed
+}
+
+func parseDuration(value string) time.Duration {
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		utils.Logger.Panicf("Cannot understand duration %s: %v", value, err)
+	}
+	return duration
 }
 
 func executeListTrace(arguments map[string]interface{}, env *environments.Environment) {
 	cmd := arguments["<numberOfCommandYouWantToCheck>"].(string)
 
+	if arguments["--replay"].(bool) {
+		executeReplay(cmd, arguments, env)
+		return
+	}
+
+	streams := getStreams(arguments)
+
+	timestamps := commands.TimestampsNone
+	switch {
+	case arguments["--timestamps"].(bool):
+		timestamps = commands.TimestampsAbsolute
+	case arguments["--relative"].(bool):
+		timestamps = commands.TimestampsRelative
+	}
+	var gap time.Duration
+	if arguments["--gap"] != nil {
+		gap = parseDuration(arguments["--gap"].(string))
+	}
+
 	utils.Logger.WithFields(logrus.Fields{
-		"cmd": cmd,
+		"cmd":        cmd,
+		"streams":    streams,
+		"timestamps": timestamps,
+		"gap":        gap,
 	}).Info("Arguments of 'listTrace'")
 
-	commands.ListTrace(cmd, env)
+	commands.ListTrace(cmd, streams, timestamps, gap, env)
+}
+
+func executeReplay(cmd string, arguments map[string]interface{}, env *environments.Environment) {
+	speed, err := strconv.ParseFloat(arguments["--speed"].(string), 64)
+	if err != nil || speed <= 0 {
+		utils.Logger.Panicf("Speed should be a positive number: %s", arguments["--speed"])
+	}
+	var idleLimit time.Duration
+	if arguments["--idle-limit"] != nil {
+		idleLimit = parseDuration(arguments["--idle-limit"].(string))
+	}
+
+	utils.Logger.WithFields(logrus.Fields{
+		"cmd":       cmd,
+		"speed":     speed,
+		"idleLimit": idleLimit,
+	}).Info("Arguments of 'replay'")
+
+	commands.Replay(cmd, speed, idleLimit, env)
 }
 
 func executeBookmark(arguments map[string]interface{}, env *environments.Environment) {
-	number, err := strconv.Atoi(arguments["<commandNumber>"].(string))
-	if err != nil {
-		utils.Logger.Panicf("Cannot understand command number: %d", number)
+	reference := arguments["<commandNumber>"].(string)
+	if !historyentries.IsReference(reference) {
+		utils.Logger.Panicf("Cannot understand command number: %s", reference)
 	}
 
 	bookmarkAs := arguments["<bookmarkAs>"].(string)
-	if !validateBookmarkName.Match(bookmarkAs) {
+	if !commands.IsValidBookmarkName(bookmarkAs) {
 		utils.Logger.Panic("Incorrect bookmark name!")
 	}
 
 	utils.Logger.WithFields(logrus.Fields{
-		"commandNumber": number,
+		"commandNumber": reference,
 		"bookmarkAs":    bookmarkAs,
 	}).Info("Arguments of 'bookmark'")
 
-	commands.Bookmark(number, bookmarkAs, env)
+	commands.Bookmark(reference, bookmarkAs, env)
 }
 
 func executeExec(arguments map[string]interface{}, env *environments.Environment) {
@@ -287,12 +653,11 @@ func executeExec(arguments map[string]interface{}, env *environments.Environment
 		"interactive": interactive,
 	}).Info("Arguments of 'bookmark'")
 
-	commandNumber, err := strconv.Atoi(commandNumberOrBookMarkName)
 	switch {
-	case err == nil:
-		utils.Logger.Info("Execute command number ", commandNumber)
-		commands.ExecuteCommandNumber(commandNumber, interactive, tty, env)
-	case validateBookmarkName.Match(commandNumberOrBookMarkName):
+	case historyentries.IsReference(commandNumberOrBookMarkName):
+		utils.Logger.Info("Execute command number ", commandNumberOrBookMarkName)
+		commands.ExecuteCommandReference(commandNumberOrBookMarkName, interactive, tty, env)
+	case commands.IsValidBookmarkName(commandNumberOrBookMarkName):
 		utils.Logger.Info("Execute bookmark ", commandNumberOrBookMarkName)
 		commands.ExecuteBookmark(commandNumberOrBookMarkName, interactive, tty, env)
 	default:
@@ -348,7 +713,7 @@ func executeRemoveBookmarks(arguments map[string]interface{}, env *environments.
 	}
 
 	for _, bookmark := range bookmarks {
-		if !validateBookmarkName.Match(bookmark) {
+		if !commands.IsValidBookmarkName(bookmark) {
 			utils.Logger.WithFields(logrus.Fields{
 				"bookmark": bookmark,
 			}).Panicf("Bookmark name %s is invalid", bookmark)
@@ -395,3 +760,109 @@ func executeAt(arguments map[string]interface{}, env *environments.Environment)
 
 	commands.AutoTeeCreate(cmd, env)
 }
+
+func executeStats(arguments map[string]interface{}, env *environments.Environment) {
+	top, err := strconv.Atoi(arguments["--top"].(string))
+	if err != nil {
+		utils.Logger.Panic(err)
+	} else if top <= 0 {
+		utils.Logger.Panic("Number of entries in top lists has to be > 0")
+	}
+	conditions := getTimeConditions(arguments)
+	if arguments["--json"].(bool) {
+		env.Format = output.FormatJSON
+	}
+
+	utils.Logger.WithFields(logrus.Fields{
+		"top":        top,
+		"conditions": len(conditions),
+		"format":     env.Format,
+	}).Info("Arguments of 'stats'")
+
+	commands.Stats(top, conditions, env)
+}
+
+func executeGrep(arguments map[string]interface{}, env *environments.Environment) {
+	pattern, err := getMatcher(arguments["<pattern>"].(string), false, getCaseMode(arguments), arguments)
+	if err != nil {
+		utils.Logger.Panic(err)
+	}
+	conditions := getTimeConditions(arguments)
+
+	utils.Logger.WithFields(logrus.Fields{
+		"pattern":    arguments["<pattern>"],
+		"conditions": len(conditions),
+	}).Info("Arguments of 'grep'")
+
+	commands.Grep(pattern, conditions, env)
+}
+
+func executePick(arguments map[string]interface{}, env *environments.Environment) {
+	query := ""
+	if arguments["<searchQuery>"] != nil {
+		query = arguments["<searchQuery>"].(string)
+	}
+
+	utils.Logger.WithField("query", query).Info("Arguments of 'pick'")
+
+	commands.Pick(query, env)
+}
+
+func executeRecord(arguments map[string]interface{}, env *environments.Environment) {
+	exitStatus, err := strconv.Atoi(arguments["--status"].(string))
+	if err != nil {
+		utils.Logger.Panic(err)
+	}
+	var started int64
+	if arguments["--started"] != nil {
+		started, err = strconv.ParseInt(arguments["--started"].(string), 10, 64)
+		if err != nil {
+			utils.Logger.Panic(err)
+		}
+	}
+	directory := ""
+	if arguments["--cwd"] != nil {
+		directory = arguments["--cwd"].(string)
+	}
+	tty := ""
+	if arguments["--terminal"] != nil {
+		tty = arguments["--terminal"].(string)
+	}
+
+	utils.Logger.WithFields(logrus.Fields{
+		"status":    exitStatus,
+		"started":   started,
+		"directory": directory,
+		"tty":       tty,
+	}).Info("Arguments of 'record'")
+
+	commands.Record(exitStatus, started, directory, tty, env)
+}
+
+func executeExport(arguments map[string]interface{}, env *environments.Environment) {
+	reference := arguments["<commandNumber>"].(string)
+	if !historyentries.IsReference(reference) {
+		utils.Logger.Panicf("Cannot understand command number: %s", reference)
+	}
+
+	utils.Logger.WithFields(logrus.Fields{
+		"commandNumber": reference,
+	}).Info("Arguments of 'export'")
+
+	commands.ExportAsciicast(reference, env)
+}
+
+func executeImport(arguments map[string]interface{}, env *environments.Environment) {
+	reference := arguments["<commandNumber>"].(string)
+	if !historyentries.IsReference(reference) {
+		utils.Logger.Panicf("Cannot understand command number: %s", reference)
+	}
+	castFile := arguments["<castFile>"].(string)
+
+	utils.Logger.WithFields(logrus.Fields{
+		"commandNumber": reference,
+		"castFile":      castFile,
+	}).Info("Arguments of 'import'")
+
+	commands.ImportAsciicast(castFile, reference, env)
+}
@@ -0,0 +1,32 @@
+#!/usr/bin/env bash
+# vim: set noexpandtab shiftwidth=4:
+
+
+# records are bound to the latest command of the history file so it is
+# appended before the command is recorded.
+__ah_precmd() {
+	local exit_status=$?
+	history -a
+	if [[ -n "${__ah_started}" ]]; then
+		( ah record --status "${exit_status}" --started "${__ah_started}" --cwd "${__ah_cwd}" --terminal "${__ah_tty}" & )
+	fi
+	unset __ah_started __ah_cwd __ah_ready
+}
+
+# DEBUG trap is executed before each simple command including ones of
+# PROMPT_COMMAND so only the first command after the prompt is taken.
+# Empty command line runs PROMPT_COMMAND only, it is not recorded.
+__ah_preexec() {
+	[[ -n "${__ah_ready}" && "${BASH_COMMAND}" != __ah_precmd* ]] || return
+	unset __ah_ready
+	printf -v __ah_started '%(%s)T' -1
+	__ah_cwd=${PWD}
+}
+
+__ah_tty=$(tty 2>/dev/null) || __ah_tty=""
+
+# commands are not recorded if DEBUG trap is used by something else.
+if [[ -z "$(trap -p DEBUG)" && "${PROMPT_COMMAND}" != *__ah_precmd* ]]; then
+	trap '__ah_preexec' DEBUG
+	PROMPT_COMMAND="__ah_precmd${PROMPT_COMMAND:+; ${PROMPT_COMMAND}}; __ah_ready=1"
+fi
@@ -12,3 +12,38 @@ __auto_ah() {
 zle -N __auto_ah_widget __auto_ah
 bindkey '^J' __auto_ah_widget
 bindkey '^M' __auto_ah_widget
+
+__ah_pick() {
+	local selected
+	selected=$(ah pick "${BUFFER}") && BUFFER="${selected}" && CURSOR=${#BUFFER}
+	zle reset-prompt
+}
+
+zle -N __ah_pick_widget __ah_pick
+bindkey '^X^R' __ah_pick_widget
+
+# records are bound to the latest command of the history file so it has to
+# be written before the command is recorded. INC_APPEND_HISTORY is set only
+# if AH_INC_APPEND_HISTORY is set, commands are not recorded otherwise.
+if [[ ! -o INC_APPEND_HISTORY && ! -o INC_APPEND_HISTORY_TIME && ! -o SHARE_HISTORY ]]; then
+	[[ -n "${AH_INC_APPEND_HISTORY}" ]] || return 0
+	setopt INC_APPEND_HISTORY
+fi
+
+zmodload zsh/datetime
+autoload -Uz add-zsh-hook
+
+__ah_preexec() {
+	__ah_started=${EPOCHSECONDS}
+	__ah_cwd=${PWD}
+}
+
+__ah_precmd() {
+	local exit_status=$?
+	[[ -n "${__ah_started}" ]] || return
+	ah record --status "${exit_status}" --started "${__ah_started}" --cwd "${__ah_cwd}" --terminal "${TTY}" &!
+	unset __ah_started __ah_cwd
+}
+
+add-zsh-hook preexec __ah_preexec
+add-zsh-hook precmd __ah_precmd